@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+var (
+	apiAddr      string
+	apiTokenFile string
+)
+
+// serveAPI 在--api-addr上提供本地控制接口，unix:开头为unix socket，tcp只能监听本机地址
+func serveAPI(e internal.Engine) (func(), error) {
+	if apiAddr == "" {
+		return func() {}, nil
+	}
+	tokenFile := apiTokenPath()
+	token, err := writeAPIToken(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	l, err := listenAPI(apiAddr)
+	if err != nil {
+		os.Remove(tokenFile)
+		return nil, err
+	}
+	// 保存最近的日志供/log/recent查询
+	_ = logger.GetlocalLogger().SetLogger(logger.AdapterMemory, `{"size":1000}`)
+	srv := &http.Server{Handler: internal.NewControlHandler(e, token)}
+	logger.Info("控制接口地址：", l.Addr().Network(), l.Addr().String(), "token文件：", tokenFile)
+	go srv.Serve(l)
+	return func() {
+		srv.Close()
+		os.Remove(tokenFile)
+	}, nil
+}
+
+// apiTokenPath 返回--api-token-file指定的文件，默认$HOME/.mts.api-token
+func apiTokenPath() string {
+	if apiTokenFile != "" {
+		return apiTokenFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".mts.api-token"
+	}
+	return filepath.Join(home, ".mts.api-token")
+}
+
+// writeAPIToken 每次启动生成随机token，写入只有当前用户可读的文件
+func writeAPIToken(file string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	// 删除后重新创建，不沿用已有文件的权限
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err = f.WriteString(token + "\n"); err != nil {
+		f.Close()
+		os.Remove(file)
+		return "", err
+	}
+	return token, f.Close()
+}
+
+// listenAPI tcp地址必须是本机回环地址
+func listenAPI(apiAddr string) (net.Listener, error) {
+	if !strings.HasPrefix(apiAddr, "unix:") {
+		host, _, err := net.SplitHostPort(apiAddr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("控制接口只能监听本机地址，如127.0.0.1:9091: %s", apiAddr)
+		}
+		return net.Listen("tcp", apiAddr)
+	}
+	addr := strings.TrimPrefix(apiAddr, "unix:")
+	// 清理上次异常退出遗留的socket文件，其他文件不删除
+	if fi, err := os.Lstat(addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是socket", addr)
+		}
+		if err = os.Remove(addr); err != nil {
+			return nil, err
+		}
+	}
+	return listenUnix(addr)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenAPIHost(t *testing.T) {
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.168.1.2:0", "example.com:0"} {
+		if l, err := listenAPI(addr); err == nil {
+			l.Close()
+			t.Fatalf("%s accepted", addr)
+		}
+	}
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0"} {
+		l, err := listenAPI(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+	}
+}
+
+func TestListenAPIUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 普通文件不删除
+	file := filepath.Join(dir, "bashrc")
+	ioutil.WriteFile(file, []byte("x"), 0600)
+	if _, err := listenAPI("unix:" + file); err == nil {
+		t.Fatal("regular file replaced")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+
+	sock := filepath.Join(dir, "mts.sock")
+	l, err := listenAPI("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(sock); fi.Mode().Perm()&0077 != 0 {
+		t.Fatalf("socket mode %v", fi.Mode())
+	}
+	// 遗留的socket文件会被清理
+	l2, err := listenAPI("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2.Close()
+	l.Close()
+}
+
+func TestWriteAPIToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 已有文件的权限不沿用
+	file := filepath.Join(dir, "token")
+	ioutil.WriteFile(file, []byte("old"), 0644)
+	token, err := writeAPIToken(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(file)
+	fi, _ := os.Stat(file)
+	if len(token) != 64 || string(b) != token+"\n" || fi.Mode().Perm() != 0600 {
+		t.Fatalf("token %q file %q mode %v", token, b, fi.Mode())
+	}
+	if token2, _ := writeAPIToken(file); token2 == token {
+		t.Fatal("token reused")
+	}
+}
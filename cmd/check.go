@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+var checkSku string
+
+// 检查结果在清单中的标记
+var checkMarks = map[string]string{
+	internal.CheckPass: "PASS",
+	internal.CheckWarn: "WARN",
+	internal.CheckFail: "FAIL",
+}
+
+// jdCheckCmd represents the jd check command
+var jdCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "抢购前检查账号、商品、收货地址和时钟",
+	Long:  "扫码登陆后检查用户信息、商品是否存在、是否为秒杀商品、收货地址和token以及服务器时间差，不提交订单；有检查项未通过时退出码为7",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return jdCheck()
+	},
+}
+
+func init() {
+	jdCmd.AddCommand(jdCheckCmd)
+	jdCheckCmd.Flags().StringVar(&checkSku, "sku", "", "要检查的商品ID，默认使用--skuId")
+}
+
+func jdCheck() error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	sku := checkSku
+	if sku == "" {
+		sku = skuId
+	}
+	if sku == "" {
+		sku = "100012043978"
+	}
+	e, f, err := resolveEidFp()
+	if err != nil {
+		return err
+	}
+	jdSnap := internal.NewjdSnap(brwoserPath, sku, num, works)
+	defer jdSnap.Stop()
+	if e != "" && f != "" {
+		jdSnap.SetEid(e)
+		jdSnap.SetFp(f)
+	}
+	list, err := jdSnap.Check()
+	printChecklist(os.Stdout, list)
+	if err != nil {
+		if browserNotFound(err) {
+			return fmt.Errorf("%w: %v", internal.ErrBrowserNotFound, err)
+		}
+		return err
+	}
+	if n := list.Failed(); n > 0 {
+		return fmt.Errorf("%w: %d项", internal.ErrCheckFailed, n)
+	}
+	return nil
+}
+
+// printChecklist 每项一行输出检查结果
+func printChecklist(w io.Writer, list internal.Checklist) {
+	if len(list) == 0 {
+		return
+	}
+	width := 0
+	for _, item := range list {
+		if n := tui.Width(item.Name); n > width {
+			width = n
+		}
+	}
+	fmt.Fprintln(w, "检查结果：")
+	for _, item := range list {
+		fmt.Fprintf(w, "[%s] %s%s  %s\n", checkMarks[item.Status], item.Name,
+			strings.Repeat(" ", width-tui.Width(item.Name)), item.Detail)
+	}
+}
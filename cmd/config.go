@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/notify"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the content of the config file
+type Config struct {
+	// Notify 通知配置，抢购成功、失败、登陆失效、即将开始时发送
+	Notify []notify.Config `yaml:"notify"`
+	// NotifyBefore 开始前多久发送即将开始的通知，默认1分钟
+	NotifyBefore time.Duration `yaml:"notifyBefore"`
+	// Record --record请求记录的配置
+	Record RecordConfig `yaml:"record"`
+	// PayPwd、Eid、Fp 命令行未传入时使用，建议使用vault:<name>引用vault中的条目
+	PayPwd string `yaml:"payPwd"`
+	Eid    string `yaml:"eid"`
+	Fp     string `yaml:"fp"`
+	// LogLevel 日志等级，如 INFO,chrome=WARN，命令行未传入--log-level时使用，收到SIGHUP时重新读取
+	LogLevel string `yaml:"logLevel"`
+}
+
+var config Config
+
+// loadConfig 读取--config指定的配置文件，未指定时读取$HOME/.mts.yaml，不存在则忽略
+func loadConfig() error {
+	file := configPath()
+	if file == "" {
+		return nil
+	}
+	if err := readConfig(file, &config); err != nil {
+		return err
+	}
+	if config.NotifyBefore <= 0 {
+		config.NotifyBefore = time.Minute
+	}
+	if config.LogLevel != "" && !logLevelSet {
+		if err := logger.SetLevel(config.LogLevel); err != nil {
+			return fmt.Errorf("%w: logLevel %v", internal.ErrConfig, err)
+		}
+	}
+	return nil
+}
+
+// configPath 返回配置文件路径，未指定且$HOME/.mts.yaml不存在时返回空
+func configPath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	file := filepath.Join(home, ".mts.yaml")
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+func readConfig(file string, c *Config) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	if err = yaml.UnmarshalStrict(b, c); err != nil {
+		return fmt.Errorf("%w: %s %v", internal.ErrConfig, file, err)
+	}
+	return nil
+}
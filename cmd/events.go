@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oldthreefeng/mts/internal"
+)
+
+var (
+	eventsFormat string
+	eventsFd     int
+	eventsOut    *os.File
+)
+
+// openEvents 按--events参数打开事件输出
+func openEvents() error {
+	if eventsFormat == "" {
+		return nil
+	}
+	if eventsFormat != "ndjson" {
+		return fmt.Errorf("%w: 不支持的事件格式 %s", internal.ErrConfig, eventsFormat)
+	}
+	switch eventsFd {
+	case 1:
+		eventsOut = os.Stdout
+	case 2:
+		eventsOut = os.Stderr
+	default:
+		eventsOut = os.NewFile(uintptr(eventsFd), "events")
+		if _, err := eventsOut.Stat(); err != nil {
+			return fmt.Errorf("%w: 事件输出文件描述符 %d 不可用 %v", internal.ErrConfig, eventsFd, err)
+		}
+	}
+	return nil
+}
+
+// subscribeEvents 将事件以ndjson格式写入事件输出
+func subscribeEvents(bus *internal.EventBus) {
+	if eventsOut == nil {
+		return
+	}
+	bus.Subscribe(internal.NDJSONWriter(eventsOut))
+}
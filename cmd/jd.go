@@ -23,8 +23,8 @@ import (
 var jdCmd = &cobra.Command{
 	Use:   "jd",
 	Short: "jd 秒杀",
-	Run: func(cmd *cobra.Command, args []string) {
-		jd()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return jd()
 	},
 }
 
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix 创建unix socket后改为只有当前用户可以访问
+func listenUnix(addr string) (net.Listener, error) {
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(addr, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,8 @@
+package cmd
+
+import "net"
+
+// listenUnix windows上socket的权限由所在目录决定
+func listenUnix(addr string) (net.Listener, error) {
+	return net.Listen("unix", addr)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+var (
+	logLevel    string
+	logLevelSet bool // 命令行传入了--log-level，优先于配置文件
+)
+
+// watchLogLevel 收到SIGHUP时重新读取配置文件中的logLevel，未配置时恢复为--log-level
+func watchLogLevel() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			reloadLogLevel()
+		}
+	}()
+}
+
+func reloadLogLevel() {
+	spec := logLevel
+	if file := configPath(); file != "" {
+		var c Config
+		if err := readConfig(file, &c); err != nil {
+			logger.Error("重新读取日志等级失败:", err)
+			return
+		}
+		if c.LogLevel != "" {
+			spec = c.LogLevel
+		}
+	}
+	if err := logger.SetLevel(spec); err != nil {
+		logger.Error("日志等级配置错误:", err)
+		return
+	}
+	logger.Warn("日志等级已更新为", logger.Level())
+}
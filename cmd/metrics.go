@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/metrics"
+)
+
+var metricsAddr string
+
+// serveMetrics 在--metrics-addr上提供/metrics，监听失败只记录日志不影响抢购
+func serveMetrics() {
+	if metricsAddr == "" {
+		return
+	}
+	l, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		logger.Error("metrics监听失败：", err)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	logger.Info("metrics地址：http://" + l.Addr().String() + "/metrics")
+	go http.Serve(l, mux)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/notify"
+	"github.com/oldthreefeng/mts/pkg/utils"
+)
+
+// newNotifier 根据配置文件创建通知，password、secret可以引用vault中的条目
+func newNotifier() (*notify.Dispatcher, error) {
+	configs := make([]notify.Config, len(config.Notify))
+	for i, c := range config.Notify {
+		var err error
+		if c.Password, err = resolveSecret(c.Password); err != nil {
+			return nil, err
+		}
+		if c.Secret, err = resolveSecret(c.Secret); err != nil {
+			return nil, err
+		}
+		configs[i] = c
+	}
+	d, err := notify.NewDispatcher(configs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	return d, nil
+}
+
+// subscribeNotify 将抢购事件转换为通知
+func subscribeNotify(d *notify.Dispatcher, bus *internal.EventBus) {
+	if d.Len() == 0 {
+		return
+	}
+	var once sync.Once
+	var mu sync.Mutex
+	var starting *time.Timer
+	bus.Subscribe(func(e internal.Event) {
+		m := notify.Message{Time: e.Time, Platform: e.Platform, SkuId: e.SkuId}
+		switch e.Type {
+		case internal.EventWaiting:
+			once.Do(func() {
+				m.Kind = notify.KindStarting
+				m.Title = "抢购即将开始"
+				m.Content = "开始时间: " + e.StartTime.Format(utils.DateTimeFormatStr)
+				mu.Lock()
+				starting = time.AfterFunc(time.Until(*e.StartTime)-config.NotifyBefore, func() {
+					m.Time = time.Now()
+					d.Send(m)
+				})
+				mu.Unlock()
+			})
+			return
+		case internal.EventOrderSucceeded:
+			m.Kind = notify.KindSuccess
+			m.Title = "抢购成功"
+			m.Content = "订单编号: " + e.OrderId
+		case internal.EventLoginExpired:
+			m.Kind = notify.KindLoginExpired
+			m.Title = "登陆已失效"
+			m.Content = "请重新登陆"
+		case internal.EventStopped:
+			mu.Lock()
+			if starting != nil {
+				starting.Stop()
+			}
+			mu.Unlock()
+			if e.ErrorClass == "" {
+				return
+			}
+			m.Kind = notify.KindFailure
+			m.Title = "抢购失败"
+			m.Content = e.Error
+			m.Fields = map[string]string{"errorClass": e.ErrorClass}
+		default:
+			return
+		}
+		d.Send(m)
+	})
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/recorder"
+)
+
+var recordFile string
+
+// RecordConfig is the record section of the config file
+type RecordConfig struct {
+	// BodyLimit 每个body最多记录的字节数，默认64KB，负数不记录body
+	BodyLimit int `yaml:"bodyLimit"`
+	// Redact 需要屏蔽的参数名，cookie表示屏蔽cookie，为空时屏蔽cookie、password、mobileKey、eid、fp
+	Redact []string `yaml:"redact"`
+}
+
+// openRecorder 按--record将所有接口请求以json lines追加写入文件，返回记录请求的transport和关闭函数，
+// 未开启时transport为nil
+func openRecorder() (http.RoundTripper, func(), error) {
+	if recordFile == "" {
+		return nil, func() {}, nil
+	}
+	f, err := os.OpenFile(recordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 请求记录文件打开失败 %v", internal.ErrConfig, err)
+	}
+	rec := recorder.New(f, recorder.Options{BodyLimit: config.Record.BodyLimit, Rules: config.Record.Redact})
+	return rec.RoundTripper(nil), func() { f.Close() }, nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/chrome"
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/recorder"
+	"github.com/spf13/cobra"
+)
+
+var replaySpeed float64
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <record file>",
+	Short: "使用--record录制的请求在本地回放京东抢购流程",
+	Long: `启动本地服务，按录制的响应和耗时应答itemShowBtn、seckill.action、init.action、submitOrder等请求，
+不启动浏览器，直接从开始抢购执行jd的抢购流程，用于复现线上失败以及验证解析和重试逻辑。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return replay(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "回放速度倍数，0为不按录制的耗时延迟响应")
+}
+
+func replay(file string) (err error) {
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	if err = openEvents(); err != nil {
+		return err
+	}
+	rf, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	entries, err := recorder.Load(rf)
+	rf.Close()
+	if err != nil {
+		return fmt.Errorf("%w: 录制文件解析失败 %v", internal.ErrConfig, err)
+	}
+	replayer := recorder.NewReplayer(entries)
+	replayer.Speed = replaySpeed
+	if replayer.Len() == 0 {
+		return fmt.Errorf("%w: 录制文件中没有可回放的请求", internal.ErrConfig)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: replayer}
+	go srv.Serve(l)
+	defer srv.Close()
+	logger.Info("回放服务地址：", l.Addr().String(), "，共", len(entries), "条请求")
+
+	sku := skuId
+	if sku == "" {
+		sku = recordedSkuId(entries)
+	}
+	var snap resulter
+	defer func() { writeResult(snap, err) }()
+	jdSnap := internal.NewjdSnap("", sku, num, works)
+	defer jdSnap.Stop()
+	snap = jdSnap
+	subscribeEvents(jdSnap.Events)
+	if jdSnap.PayPwd, err = resolvePayPwd(); err != nil {
+		return err
+	}
+	e, f, err := resolveEidFp()
+	if err != nil {
+		return err
+	}
+	jdSnap.SetEid(e)
+	jdSnap.SetFp(f)
+	jdSnap.StartTime = time.Now()
+	jdSnap.Timeout = timeout
+	if jdSnap.Timeout <= 0 {
+		jdSnap.Timeout = 30 * time.Second
+	}
+	if err = jdSnap.Configure(internal.EngineConfig{Strategy: &strategy}); err != nil {
+		return fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	stopUI, err := startUI("mts replay", jdSnap, jdSnap.Events)
+	if err != nil {
+		return err
+	}
+	err = jdSnap.Replay(chrome.WithTransport(context.Background(), recorder.Transport(l.Addr().String())))
+	stopUI()
+	res := jdSnap.Result()
+	logger.Info("回放结束，尝试次数：", res.Attempts, "订单编号：", res.OrderId)
+	return err
+}
+
+// recordedSkuId 从录制的seckill.action或itemShowBtn请求中取得skuId
+func recordedSkuId(entries []recorder.Entry) string {
+	for _, e := range entries {
+		if !strings.Contains(e.URL, "seckill.action") && !strings.Contains(e.URL, "itemShowBtn") {
+			continue
+		}
+		if u, err := url.Parse(e.URL); err == nil && u.Query().Get("skuId") != "" {
+			return u.Query().Get("skuId")
+		}
+	}
+	return ""
+}
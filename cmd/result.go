@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+// 进程退出码，供外部脚本判断运行结果
+const (
+	ExitOK             = 0 // 抢购成功
+	ExitUnknown        = 1 // 其他错误
+	ExitSoldOut        = 2 // 商品已售罄
+	ExitLoginFailed    = 3 // 登陆失败
+	ExitBrowserMissing = 4 // 浏览器未找到
+	ExitTimeout        = 5 // 超时未抢到
+	ExitConfig         = 6 // 参数或配置错误
+	ExitCheckFailed    = 7 // jd check有检查项未通过
+	ExitStopped        = 8 // 未下单即停止，如关闭浏览器或通过控制接口停止
+)
+
+var exitCodes = map[string]int{
+	"":                            ExitOK,
+	internal.ClassSoldOut:         ExitSoldOut,
+	internal.ClassLoginFailed:     ExitLoginFailed,
+	internal.ClassBrowserNotFound: ExitBrowserMissing,
+	internal.ClassTimeout:         ExitTimeout,
+	internal.ClassConfig:          ExitConfig,
+	internal.ClassCheckFailed:     ExitCheckFailed,
+	internal.ClassStopped:         ExitStopped,
+}
+
+// ExitCode return the process exit code for err
+func ExitCode(err error) int {
+	if code, ok := exitCodes[internal.ErrorClass(err)]; ok {
+		return code
+	}
+	return ExitUnknown
+}
+
+// resulter is implemented by jdSnap and tmSecKill
+type resulter interface {
+	Result() *internal.Result
+}
+
+// writeResult 将最终结果写入--result-file指定的文件
+func writeResult(s resulter, err error) {
+	if resultFile == "" || s == nil {
+		return
+	}
+	r := s.Result()
+	r.SetErr(err)
+	b, _ := json.MarshalIndent(r, "", "  ")
+	if e := ioutil.WriteFile(resultFile, append(b, '\n'), 0644); e != nil {
+		logger.Error("结果文件写入失败：", e)
+	}
+}
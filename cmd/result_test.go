@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oldthreefeng/mts/internal"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitOK},
+		{errors.New("x"), ExitUnknown},
+		{fmt.Errorf("提交失败: %w", internal.ErrSoldOut), ExitSoldOut},
+		{internal.ErrLoginFailed, ExitLoginFailed},
+		{fmt.Errorf("%w: exec", internal.ErrBrowserNotFound), ExitBrowserMissing},
+		{internal.ErrTimeout, ExitTimeout},
+		{fmt.Errorf("%w: --ui", internal.ErrConfig), ExitConfig},
+		{fmt.Errorf("%w: 1项", internal.ErrCheckFailed), ExitCheckFailed},
+		{fmt.Errorf("%w: %v", internal.ErrStopped, context.Canceled), ExitStopped},
+	} {
+		if code := ExitCode(c.err); code != c.code {
+			t.Fatalf("%v: got %d, want %d", c.err, code, c.code)
+		}
+	}
+}
+
+type fakeResulter internal.Result
+
+func (f *fakeResulter) Result() *internal.Result {
+	r := internal.Result(*f)
+	return &r
+}
+
+func TestWriteResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resultFile = filepath.Join(dir, "result.json")
+	defer func() { resultFile = "" }()
+
+	writeResult(&fakeResulter{Platform: "jd", SkuId: "100012043978", Attempts: 3}, internal.ErrStopped)
+	b, err := ioutil.ReadFile(resultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r internal.Result
+	if err = json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.SkuId != "100012043978" || r.Attempts != 3 || r.ErrorClass != internal.ClassStopped || r.Error != internal.ErrStopped.Error() {
+		t.Fatalf("unexpected result %s", b)
+	}
+	// 未设置--result-file时不写入
+	resultFile = ""
+	writeResult(&fakeResulter{}, nil)
+}
@@ -17,12 +17,20 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/chrome"
 	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/notify"
 	"github.com/oldthreefeng/mts/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -35,18 +43,23 @@ var rootCmd = &cobra.Command{
 	Short: "mts is jd/tm sanp up tools, default is jd",
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
-	Run: func(cmd *cobra.Command, args []string) { 
-		jd()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return jd()
 	},
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The exit code is one of the Exit* constants.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error(err)
-		os.Exit(1)
+		// 执行退出hook，日志写完后再退出
+		logger.Exit(ExitCode(err))
 	}
+	logger.Flush()
 }
 
 func init() {
@@ -64,9 +77,35 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&brwoserPath, "brwoserPath", "", "chrome浏览器执行路径，路径不能有空格")
 	rootCmd.PersistentFlags().StringVar(&eid, "eid", EnvDefault("JD_EID",""), "如果不传入，可自动获取，对于无法获取的用户可手动传入参数")
 	rootCmd.PersistentFlags().StringVar(&fp, "fp",  EnvDefault("JD_FP",""), "如果不传入，可自动获取，对于无法获取的用户可手动传入参数")
-	rootCmd.PersistentFlags().StringVar(&payPwd, "payPwd", "", "支付密码 可不填")
+	rootCmd.PersistentFlags().StringVar(&payPwd, "payPwd", "", "支付密码 可不填，会出现在进程列表中，建议使用--payPwd-file、环境变量JD_PAY_PWD或--payPwd-prompt")
+	rootCmd.PersistentFlags().StringVar(&payPwdFile, "payPwd-file", "", "从文件读取支付密码")
+	rootCmd.PersistentFlags().StringVar(&vaultFile, "vault", "", "vault文件 (default is $HOME/.mts.vault)")
+	rootCmd.PersistentFlags().BoolVar(&payPwdPrompt, "payPwd-prompt", false, "启动时在终端输入支付密码，不回显")
 	rootCmd.PersistentFlags().BoolVarP(&version, "version", "v", false, "版本号")
 	rootCmd.PersistentFlags().BoolVar(&isFileLog, "log", false, "是否使用文件记录日志")
+	rootCmd.PersistentFlags().IntVar(&logMaxFiles, "log-max-files", 0, "--log切分后最多保留的日志文件数，0为不限制")
+	rootCmd.PersistentFlags().IntVar(&logMaxDays, "log-max-days", -1, "--log切分后的日志文件保留天数，-1为不限制")
+	rootCmd.PersistentFlags().BoolVar(&logCompress, "log-compress", false, "--log切分后的日志文件压缩为.gz")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logger.FormatText, "日志格式：text或json，json格式每行一个对象，包含worker、skuId等字段")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "DEBUG", "日志等级，可按包名单独设置，如INFO,chrome=WARN,internal=DEBUG，运行中可通过SIGHUP或控制接口修改")
+	rootCmd.PersistentFlags().BoolVar(&logAsync, "log-async", false, "异步输出日志，抢购时不阻塞在日志写入上")
+	rootCmd.PersistentFlags().StringVar(&logOverflow, "log-overflow", logger.OverflowDropLevelBelow, "异步日志队列满时的策略：block、drop-oldest或drop-level-below(丢弃WARN以下日志)")
+	rootCmd.PersistentFlags().StringVar(&logLayout, "log-layout", "", "文本日志的布局模板，如'{time:15:04:05.000} {level} {caller} {msg} {fields}'，可用{time} {level} {caller} {func} {fields} {msg} {app}")
+	rootCmd.PersistentFlags().BoolVar(&cdpDebug, "cdp-debug", false, "以TRAC等级输出所有chrome协议消息，用于排查浏览器问题")
+	rootCmd.PersistentFlags().BoolVar(&logSample, "log-sample", false, "开启日志去重和限流：合并同一位置连续重复的日志，WARN以下每个位置每秒最多输出10条，其余汇总输出")
+	rootCmd.PersistentFlags().StringVar(&logSampleWindow, "log-sample-window", "1s", "--log-sample的统计窗口")
+	rootCmd.PersistentFlags().IntVar(&logSampleBurst, "log-sample-burst", 10, "--log-sample时WARN以下每个位置在窗口内最多输出的条数，小于0不限流")
+	rootCmd.PersistentFlags().StringVar(&logSampleLevels, "log-sample-levels", "", "--log-sample按等级设置的条数，如DEBG=2,INFO=5,EROR=100，WARN及以上默认不限流")
+	rootCmd.PersistentFlags().StringVar(&resultFile, "result-file", "", "运行结束后将结果以json格式写入该文件")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "开始时间之后超过该时长仍未抢到则退出，0为不限制")
+	rootCmd.PersistentFlags().StringVar(&eventsFormat, "events", "", "输出生命周期事件流，目前支持ndjson")
+	rootCmd.PersistentFlags().IntVar(&eventsFd, "events-fd", 1, "事件流输出的文件描述符，为1时日志改为输出到stderr")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "prometheus指标监听地址，如127.0.0.1:9090")
+	rootCmd.PersistentFlags().StringVar(&apiAddr, "api-addr", "", "本地控制接口监听地址，只能为本机地址如127.0.0.1:9091，或unix:/tmp/mts.sock")
+	rootCmd.PersistentFlags().StringVar(&apiTokenFile, "api-token-file", "", "控制接口token写入的文件，每次启动随机生成 (default is $HOME/.mts.api-token)")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "将所有接口请求和响应以json lines格式追加写入该文件，cookie等敏感参数会被屏蔽")
+	rootCmd.PersistentFlags().StringVar(&uiMode, "ui", uiLog, "运行界面：log为普通日志，tui为终端实时面板，stdout不是终端时改为普通日志")
+	rootCmd.PersistentFlags().StringVar(&strategy, "strategy", internal.StrategyJitter, "提交失败后的重试策略：jitter随机等待0-200ms，burst立即重试")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 }
@@ -78,9 +117,43 @@ func initConfig() {
 		os.Exit(0)
 	}
 	if isFileLog {
-		logger.Cfg(6, "mts.log")
+		logger.Cfg(logger.LevelDebug, "mts.log", logFormat)
 	} else {
-		logger.Cfg(6, "")
+		logger.Cfg(logger.LevelDebug, "", logFormat)
+	}
+	if err := setLogRetention(); err != nil {
+		logger.Error("日志清理参数错误:", err)
+		logger.Exit(ExitConfig)
+	}
+	if err := logger.SetLevel(logLevel); err != nil {
+		logger.Error("--log-level参数错误:", err)
+		logger.Exit(ExitConfig)
+	}
+	logLevelSet = rootCmd.PersistentFlags().Changed("log-level")
+	watchLogLevel()
+	if eventsFormat != "" && eventsFd == 1 {
+		// 事件流占用stdout，日志改为输出到stderr
+		logger.SetLogger(`{"Console":{"level":"TRAC","color":true,"output":"stderr","format":"` + logFormat + `"}}`)
+	}
+	if logLayout != "" {
+		if err := logger.SetLayout(logLayout); err != nil {
+			logger.Error("--log-layout参数错误:", err)
+			logger.Exit(ExitConfig)
+		}
+	}
+	// 第三方库使用标准库log输出的日志
+	logger.RedirectStdLog(logger.LevelInformational)
+	chrome.DebugProtocol = cdpDebug
+	if logSample {
+		if err := setLogSampling(); err != nil {
+			logger.Error("日志限流参数错误:", err)
+			logger.Exit(ExitConfig)
+		}
+	}
+	if logAsync {
+		if err := logger.GetlocalLogger().SetAsync(&logger.AsyncConfig{Overflow: logOverflow}); err != nil {
+			logger.Warn("异步日志配置错误，使用同步输出:", err)
+		}
 	}
 }
 
@@ -94,9 +167,67 @@ var (
 	fp          string
 	payPwd      string
 	isFileLog   bool
+	logFormat   string
+	logMaxFiles int
+	logMaxDays  int
+	logCompress bool
+	logAsync    bool
+	logOverflow string
+	logSample   bool
+	logLayout   string
+	cdpDebug    bool
 	version 	bool
+	resultFile  string
+	timeout     time.Duration
+	strategy    string
+)
+
+
+// setLogRetention 按--log-max-files等参数设置文件日志的清理和压缩，默认都不开启
+func setLogRetention() error {
+	if !isFileLog || (logMaxFiles == 0 && logMaxDays == -1 && !logCompress) {
+		return nil
+	}
+	l := logger.GetlocalLogger()
+	typ, cfg, ok := l.OutputConfig(logger.AdapterFile)
+	if !ok {
+		return nil
+	}
+	c := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(cfg), &c); err != nil {
+		return err
+	}
+	c["maxfiles"], c["maxdays"], c["compress"] = logMaxFiles, logMaxDays, logCompress
+	b, _ := json.Marshal(c)
+	return l.SetOutput(logger.AdapterFile, typ, string(b))
+}
+
+// --log-sample-*参数
+var (
+	logSampleWindow string
+	logSampleBurst  int
+	logSampleLevels string
 )
 
+// setLogSampling 按--log-sample-*参数开启日志去重和限流
+func setLogSampling() error {
+	c := &logger.SampleConfig{Window: logSampleWindow, Burst: logSampleBurst}
+	if logSampleLevels != "" {
+		c.Levels = map[string]int{}
+		for _, item := range strings.Split(logSampleLevels, ",") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("%s 应为 等级=条数", item)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return fmt.Errorf("%s 应为 等级=条数", item)
+			}
+			c.Levels[strings.TrimSpace(kv[0])] = n
+		}
+	}
+	return logger.SetSampling(c)
+}
 
 func EnvDefault(key, defVal string) string {
 	val, ex := os.LookupEnv(key)
@@ -107,8 +238,24 @@ func EnvDefault(key, defVal string) string {
 	return val
 }
 
-func jd() {
-	var err error
+func jd() (err error) {
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	if err = openEvents(); err != nil {
+		return err
+	}
+	transport, closeRecorder, err := openRecorder()
+	if err != nil {
+		return err
+	}
+	defer closeRecorder()
+	notifier, err := newNotifier()
+	if err != nil {
+		return err
+	}
+	defer notifier.Wait(10 * time.Second)
+	serveMetrics()
 	execPath := ""
 	if brwoserPath != "" {
 		execPath = brwoserPath
@@ -119,48 +266,101 @@ func jd() {
 	if skuId == "" {
 		skuId = "100012043978"
 	}
+	pwd, err := resolvePayPwd()
+	if err != nil {
+		return err
+	}
+	e, f, err := resolveEidFp()
+	if err != nil {
+		return err
+	}
+	var snap resulter
+	defer func() { writeResult(snap, err) }()
 	RE:
+	snap, err = jdRun(execPath, pwd, e, f, notifier, transport)
+	if browserNotFound(err) {
+		if execPath = readExecPath(execPath); execPath == "" {
+			return fmt.Errorf("%w: %v", internal.ErrBrowserNotFound, err)
+		}
+		goto RE
+	}
+	return err
+}
+
+// jdRun 使用execPath执行一次抢购，界面和控制接口在返回前关闭
+func jdRun(execPath string, pwd logger.Secret, e, f string, notifier *notify.Dispatcher, transport http.RoundTripper) (resulter, error) {
 	jdSnap := internal.NewjdSnap(execPath, skuId, num, works)
+	if transport != nil {
+		jdSnap.SetTransport(transport)
+	}
+	subscribeEvents(jdSnap.Events)
+	subscribeNotify(notifier, jdSnap.Events)
+	var err error
 	jdSnap.StartTime, err = utils.Hour2Unix(start)
 	if err != nil {
-		logger.Fatal("开始时间初始化失败", err)
+		return jdSnap, fmt.Errorf("%w: 开始时间初始化失败 %v", internal.ErrConfig, err)
 	}
 
-	jdSnap.PayPwd = payPwd
-	if eid != "" {
-		if fp == "" {
-			logger.Fatal("请传入fp参数")
+	jdSnap.PayPwd = pwd
+	jdSnap.Timeout = timeout
+	if err = jdSnap.Configure(internal.EngineConfig{Strategy: &strategy}); err != nil {
+		return jdSnap, fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	if e != "" {
+		if f == "" {
+			return jdSnap, fmt.Errorf("%w: 请传入fp参数", internal.ErrConfig)
 		}
-		jdSnap.SetEid(eid)
+		jdSnap.SetEid(e)
 	}
 
-	if fp != "" {
-		if eid == "" {
-			logger.Fatal("请传入eid参数")
+	if f != "" {
+		if e == "" {
+			return jdSnap, fmt.Errorf("%w: 请传入eid参数", internal.ErrConfig)
 		}
-		jdSnap.SetFp(fp)
+		jdSnap.SetFp(f)
 	}
 
 	if jdSnap.StartTime.Unix() < time.Now().Unix() {
 		jdSnap.StartTime = jdSnap.StartTime.AddDate(0, 0, 1)
 	}
-	jdSnap.SyncJdTime()
+	stopUI, err := startUI("mts jd", jdSnap, jdSnap.Events)
+	if err != nil {
+		return jdSnap, err
+	}
+	defer stopUI()
+	if err = jdSnap.SyncJdTime(); err != nil {
+		return jdSnap, fmt.Errorf("同步京东服务器时间失败: %w", err)
+	}
 	logger.Info("开始执行时间为：", jdSnap.StartTime.Format(utils.DateTimeFormatStr))
 
-	err = jdSnap.Run()
+	closeAPI, err := serveAPI(jdSnap)
 	if err != nil {
-		if strings.Contains(err.Error(), "exec") {
-			logger.Info("默认浏览器执行路径未找到，"+execPath+"  请重新输入：")
-			scanner := bufio.NewScanner(os.Stdin)
-			for scanner.Scan() {
-				execPath = scanner.Text()
-				if execPath != "" {
-					break
-				}
-			}
-			goto RE
+		return jdSnap, fmt.Errorf("%w: 控制接口监听失败 %v", internal.ErrConfig, err)
+	}
+	defer closeAPI()
+
+	return jdSnap, jdSnap.Run()
+}
+
+// browserNotFound 判断err是否为浏览器执行文件不存在或无法启动
+func browserNotFound(err error) bool {
+	var e *exec.Error
+	if errors.As(err, &e) {
+		return true
+	}
+	var p *os.PathError
+	return errors.As(err, &p) && p.Op == "fork/exec"
+}
+
+// readExecPath 从标准输入读取浏览器执行路径，输入结束时返回空
+func readExecPath(execPath string) string {
+	logger.Info("默认浏览器执行路径未找到，"+execPath+"  请重新输入：")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if p := scanner.Text(); p != "" {
+			return p
 		}
-		logger.Fatal(err)
 	}
+	return ""
 }
 
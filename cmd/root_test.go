@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestBrowserNotFound(t *testing.T) {
+	_, lookErr := exec.LookPath("mts-no-such-browser")
+	startErr := exec.Command("/mts/no/such/browser").Start()
+	for _, c := range []struct {
+		err      error
+		notFound bool
+	}{
+		{lookErr, true},
+		{startErr, true},
+		{fmt.Errorf("启动浏览器失败: %w", lookErr), true},
+		{errors.New("exec: context canceled"), false},
+		{&os.PathError{Op: "open", Path: "/tmp/x", Err: os.ErrNotExist}, false},
+		{nil, false},
+	} {
+		if got := browserNotFound(c.err); got != c.notFound {
+			t.Fatalf("%v: got %v, want %v", c.err, got, c.notFound)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/vault"
+	"golang.org/x/term"
+)
+
+var (
+	payPwdFile   string
+	payPwdPrompt bool
+)
+
+// resolvePayPwd 读取支付密码，命令行的--payPwd、--payPwd-file、--payPwd-prompt只能使用一个，
+// 优先于环境变量JD_PAY_PWD和配置文件payPwd；取值可以是vault:<name>引用
+func resolvePayPwd() (logger.Secret, error) {
+	n := 0
+	for _, set := range []bool{payPwd != "", payPwdFile != "", payPwdPrompt} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return "", fmt.Errorf("%w: --payPwd、--payPwd-file、--payPwd-prompt只能使用一个", internal.ErrConfig)
+	}
+	var s string
+	switch {
+	case payPwd != "":
+		if !vault.IsRef(payPwd) {
+			logger.Warn("--payPwd 会出现在进程列表和shell历史中，建议使用 --payPwd-file、JD_PAY_PWD、--payPwd-prompt 或 vault")
+		}
+		s = payPwd
+	case payPwdFile != "":
+		return readSecretFile(payPwdFile)
+	case payPwdPrompt:
+		return promptSecret("请输入支付密码：")
+	case os.Getenv("JD_PAY_PWD") != "":
+		s = os.Getenv("JD_PAY_PWD")
+	case config.PayPwd != "":
+		s = config.PayPwd
+	}
+	s, err := resolveSecret(s)
+	if err != nil {
+		return "", err
+	}
+	return logger.NewSecret(s), nil
+}
+
+// resolveEidFp 返回--eid、--fp，未传入时使用配置文件中的值，取值可以是vault:<name>引用
+func resolveEidFp() (string, string, error) {
+	e, f := eid, fp
+	if e == "" && f == "" {
+		e, f = config.Eid, config.Fp
+	}
+	e, err := resolveSecret(e)
+	if err != nil {
+		return "", "", err
+	}
+	f, err = resolveSecret(f)
+	if err != nil {
+		return "", "", err
+	}
+	return e, f, nil
+}
+
+// readSecretFile 读取文件中的密码，去掉结尾的换行
+func readSecretFile(file string) (logger.Secret, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		logger.Warn(file, " 的权限为", fi.Mode().Perm(), "，建议修改为0600")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	return logger.NewSecret(strings.TrimRight(string(b), "\r\n")), nil
+}
+
+// promptSecret 从终端读取输入，不回显
+func promptSecret(prompt string) (logger.Secret, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%w: 标准输入不是终端，无法读取密码", internal.ErrConfig)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	return logger.NewSecret(string(b)), nil
+}
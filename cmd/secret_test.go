@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/oldthreefeng/mts/internal"
+)
+
+func TestResolvePayPwd(t *testing.T) {
+	defer func() { payPwd, payPwdFile, payPwdPrompt = "", "", false }()
+	os.Setenv("JD_PAY_PWD", "env-secret")
+	defer os.Unsetenv("JD_PAY_PWD")
+
+	if pwd, err := resolvePayPwd(); err != nil || pwd.Reveal() != "env-secret" {
+		t.Fatalf("env: %v %v", pwd, err)
+	}
+	payPwd = "flag-secret"
+	if pwd, err := resolvePayPwd(); err != nil || pwd.Reveal() != "flag-secret" {
+		t.Fatalf("flag: %v %v", pwd, err)
+	}
+	// 明确要求在终端输入时不使用环境变量，标准输入不是终端时报错
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	payPwd, payPwdPrompt = "", true
+	if _, err := resolvePayPwd(); !errors.Is(err, internal.ErrConfig) {
+		t.Fatalf("prompt fell back to env: %v", err)
+	}
+	payPwd = "flag-secret"
+	if _, err := resolvePayPwd(); !errors.Is(err, internal.ErrConfig) {
+		t.Fatalf("conflicting flags accepted: %v", err)
+	}
+}
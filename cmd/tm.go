@@ -16,13 +16,12 @@ limitations under the License.
 package cmd
 
 import (
-	"bufio"
-	"os"
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/oldthreefeng/mts/internal"
 	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/notify"
 	"github.com/oldthreefeng/mts/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -31,8 +30,8 @@ import (
 var tmCmd = &cobra.Command{
 	Use:   "tm",
 	Short: "tm 秒杀",
-	Run: func(cmd *cobra.Command, args []string) {
-		tm()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return tm()
 	},
 }
 
@@ -40,8 +39,25 @@ func init() {
 	rootCmd.AddCommand(tmCmd)
 }
 
-func tm() {
-	var err error
+func tm() (err error) {
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	if err = openEvents(); err != nil {
+		return err
+	}
+	// 天猫的请求都由浏览器发出，不经过recorder的transport
+	_, closeRecorder, err := openRecorder()
+	if err != nil {
+		return err
+	}
+	defer closeRecorder()
+	notifier, err := newNotifier()
+	if err != nil {
+		return err
+	}
+	defer notifier.Wait(10 * time.Second)
+	serveMetrics()
 	execPath := ""
 	if start == ""{
 		start = "19:59:58"
@@ -49,27 +65,48 @@ func tm() {
 	if skuId == "" {
 		skuId = "20739895092"
 	}
+	var snap resulter
+	defer func() { writeResult(snap, err) }()
 	RE:
+	snap, err = tmRun(execPath, notifier)
+	if browserNotFound(err) {
+		if execPath = readExecPath(execPath); execPath == "" {
+			return fmt.Errorf("%w: %v", internal.ErrBrowserNotFound, err)
+		}
+		goto RE
+	}
+	return err
+}
+
+// tmRun 使用execPath执行一次抢购，界面和控制接口在返回前关闭
+func tmRun(execPath string, notifier *notify.Dispatcher) (resulter, error) {
 	tmSecKill := internal.NewTmSecKill(execPath, skuId, num, works)
+	subscribeEvents(tmSecKill.Events)
+	subscribeNotify(notifier, tmSecKill.Events)
+	var err error
 	tmSecKill.StartTime, err = utils.Hour2Unix(start)
+	if err != nil {
+		return tmSecKill, fmt.Errorf("%w: 开始时间初始化失败 %v", internal.ErrConfig, err)
+	}
+	tmSecKill.Timeout = timeout
+	if err = tmSecKill.Configure(internal.EngineConfig{Strategy: &strategy}); err != nil {
+		return tmSecKill, fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
 	if tmSecKill.StartTime.Unix() < time.Now().Unix() {
 		tmSecKill.StartTime = tmSecKill.StartTime.AddDate(0, 0, 1)
 	}
+	stopUI, err := startUI("mts tm", tmSecKill, tmSecKill.Events)
+	if err != nil {
+		return tmSecKill, err
+	}
+	defer stopUI()
 	logger.Info("开始执行时间为：", tmSecKill.StartTime.Format(utils.DateTimeFormatStr))
 
-	err = tmSecKill.Run()
+	closeAPI, err := serveAPI(tmSecKill)
 	if err != nil {
-		if strings.Contains(err.Error(), "exec") {
-			logger.Info("默认浏览器执行路径未找到，"+execPath+"  请重新输入：")
-			scanner := bufio.NewScanner(os.Stdin)
-			for scanner.Scan() {
-				execPath = scanner.Text()
-				if execPath != "" {
-					break
-				}
-			}
-			goto RE
-		}
-		logger.Fatal(err)
+		return tmSecKill, fmt.Errorf("%w: 控制接口监听失败 %v", internal.ErrConfig, err)
 	}
-} 	
\ No newline at end of file
+	defer closeAPI()
+
+	return tmSecKill, tmSecKill.Run()
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/tui"
+	"golang.org/x/term"
+)
+
+const (
+	uiLog = "log"
+	uiTui = "tui"
+
+	tuiOutput   = "tui" // 界面日志窗格使用的内存输出名
+	tuiInterval = 100 * time.Millisecond
+	tuiHistory  = 120 // 每个阶段保留的耗时个数
+)
+
+var uiMode string
+
+// 界面中按顺序显示的阶段和结果
+var (
+	tuiStages  = []string{internal.StageItemShowBtn, internal.StageSecKill, internal.StageInit, internal.StageSubmit}
+	tuiResults = []struct{ result, name string }{
+		{internal.AttemptOk, "成功"},
+		{internal.AttemptEmpty, "空数据"},
+		{internal.AttemptFail, "失败"},
+		{internal.AttemptError, "错误"},
+	}
+)
+
+// dashboard 汇总引擎事件和状态，定时重绘--ui tui界面
+type dashboard struct {
+	title  string
+	engine internal.Engine
+	screen *tui.Screen
+	mem    *logger.MemoryLogger
+	stop   chan struct{}
+	done   chan struct{}
+	sig    chan os.Signal
+
+	mu       sync.Mutex
+	login    string
+	synced   bool
+	attempts int64
+	results  map[string]int64
+	workers  map[int]*tui.Worker
+	latency  map[string][]float64
+}
+
+// startUI 按--ui启动运行界面，返回关闭界面的函数，可重复调用；
+// stdout不是终端或被事件流占用时使用普通日志输出
+func startUI(title string, e internal.Engine, bus *internal.EventBus) (func(), error) {
+	switch uiMode {
+	case "", uiLog:
+		return func() {}, nil
+	case uiTui:
+	default:
+		return nil, fmt.Errorf("%w: 不支持的界面 %s", internal.ErrConfig, uiMode)
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) || eventsOut == os.Stdout {
+		logger.Warn("stdout不是终端，--ui tui改为普通日志输出")
+		return func() {}, nil
+	}
+	l := logger.GetlocalLogger()
+	if err := l.SetOutput(tuiOutput, logger.AdapterMemory, `{"size":1000}`); err != nil {
+		return nil, err
+	}
+	// 控制台日志会破坏界面，关闭界面后按原配置恢复
+	typ, config, console := l.OutputConfig(logger.AdapterConsole)
+	if console {
+		l.DelLogger(logger.AdapterConsole)
+	}
+	d := &dashboard{
+		title:   title,
+		engine:  e,
+		screen:  tui.NewScreen(os.Stdout),
+		mem:     l.MemoryOutput(tuiOutput),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		sig:     make(chan os.Signal, 1),
+		login:   "未登录",
+		results: make(map[string]int64),
+		workers: make(map[int]*tui.Worker),
+		latency: make(map[string][]float64),
+	}
+	bus.Subscribe(d.onEvent)
+	d.screen.Start()
+	go d.loop()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(d.sig)
+			close(d.stop)
+			<-d.done
+			d.screen.Stop()
+			logs := d.logs(20)
+			l.DelLogger(tuiOutput)
+			if console {
+				_ = l.SetOutput(logger.AdapterConsole, typ, config)
+			}
+			// 界面关闭后屏幕恢复原样，输出最后的日志便于查看结果
+			for _, s := range logs {
+				fmt.Fprintln(os.Stdout, s)
+			}
+		})
+	}
+	// Ctrl-C或Fatal退出时也要先关闭界面，否则终端停留在备用屏幕且没有光标
+	logger.RegisterExitHook(stop)
+	signal.Notify(d.sig, os.Interrupt, syscall.SIGTERM)
+	go d.watchSignal()
+	return stop, nil
+}
+
+// watchSignal 收到退出信号时停止抢购，由Run返回后按正常流程写结果、关闭界面；
+// 停止过程中再次收到信号时关闭界面后直接退出
+func (d *dashboard) watchSignal() {
+	select {
+	case s := <-d.sig:
+		logger.Warn("收到信号%v，停止抢购", s)
+		d.engine.Stop()
+	case <-d.stop:
+		return
+	}
+	select {
+	case <-d.sig:
+		logger.Exit(ExitStopped)
+	case <-d.stop:
+	}
+}
+
+func (d *dashboard) onEvent(e internal.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch e.Type {
+	case internal.EventLoginWaiting:
+		d.login = "等待扫码"
+	case internal.EventLoginOk:
+		d.login = "已登录"
+	case internal.EventLoginExpired:
+		d.login = "登录已过期"
+	case internal.EventTimeSynced:
+		d.synced = true
+	case internal.EventAttempt:
+		d.attempts++
+		d.results[e.Result]++
+		w := d.workers[e.Worker]
+		if w == nil {
+			w = &tui.Worker{ID: e.Worker}
+			d.workers[e.Worker] = w
+		}
+		w.Stage, w.Result, w.Latency = e.Stage, e.Result, e.LatencyMs
+		w.Attempts++
+		s := append(d.latency[e.Stage], e.LatencyMs)
+		if len(s) > tuiHistory {
+			s = s[len(s)-tuiHistory:]
+		}
+		d.latency[e.Stage] = s
+	}
+}
+
+func (d *dashboard) loop() {
+	defer close(d.done)
+	t := time.NewTicker(tuiInterval)
+	defer t.Stop()
+	for {
+		d.draw()
+		select {
+		case <-d.stop:
+			d.draw()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	d.screen.Draw(tui.Render(d.state(height), width, height))
+}
+
+// state 生成当前一帧的内容，日志最多取一屏
+func (d *dashboard) state(lines int) *tui.State {
+	st := d.engine.Status()
+	s := &tui.State{
+		Title:       d.title,
+		Phase:       st.Phase,
+		Sku:         st.SkuId,
+		User:        st.User,
+		Address:     st.Address,
+		OrderId:     st.OrderId,
+		StartTime:   st.StartTime,
+		Countdown:   time.Duration(st.CountdownMs) * time.Millisecond,
+		Offset:      st.ClockOffset,
+		Uncertainty: st.ClockUncertainty,
+		Logs:        d.logs(lines),
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.Login, s.Synced = d.login, d.synced
+	s.Counters = append(s.Counters, tui.Counter{Name: "尝试", Value: d.attempts})
+	for _, r := range tuiResults {
+		s.Counters = append(s.Counters, tui.Counter{Name: r.name, Value: d.results[r.result]})
+	}
+	for i := 1; i <= st.Works || d.workers[i] != nil; i++ {
+		w := tui.Worker{ID: i}
+		if p := d.workers[i]; p != nil {
+			w = *p
+		}
+		s.Workers = append(s.Workers, w)
+	}
+	for _, stage := range tuiStages {
+		if v := d.latency[stage]; len(v) > 0 {
+			s.Latency = append(s.Latency, tui.Series{Name: stage, Values: append([]float64(nil), v...)})
+		}
+	}
+	return s
+}
+
+// logs 返回最近n条日志，格式与控制台文本日志相同
+func (d *dashboard) logs(n int) []string {
+	rs := d.mem.Records(n)
+	out := make([]string, 0, len(rs))
+	for _, r := range rs {
+		var b strings.Builder
+		b.WriteString(r.Time.Format("15:04:05.000 [") + r.Level + "] " + r.Msg)
+		for _, f := range r.Fields {
+			fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+		}
+		// 多行日志只显示第一行
+		s := b.String()
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[:i]
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oldthreefeng/mts/internal"
+	"github.com/oldthreefeng/mts/pkg/logger"
+	"github.com/oldthreefeng/mts/pkg/vault"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	vaultFile   string
+	openedVault *vault.Vault
+)
+
+// vaultCmd represents the vault command
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "加密保存支付密码、eid、fp等敏感信息",
+	Long: `vault将敏感信息保存在一个加密文件中(scrypt派生密钥，AES-256-GCM加密，权限0600)，
+口令从环境变量MTS_VAULT_PASSPHRASE读取，未设置时在终端输入。
+配置文件、--payPwd、--eid、--fp等可以使用vault:<name>引用其中的条目。`,
+}
+
+var vaultInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "创建vault文件",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pass, err := vaultPassphrase(true)
+		if err != nil {
+			return err
+		}
+		if _, err = vault.Init(vaultPath(), pass); err != nil {
+			return fmt.Errorf("%w: %v", internal.ErrConfig, err)
+		}
+		logger.Info("vault已创建：", vaultPath())
+		return nil
+	},
+}
+
+var vaultSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "保存条目，值在终端输入不回显，或从标准输入读取",
+	Long: `保存条目。值不能作为参数传入，避免出现在ps和shell历史中：
+标准输入为终端时提示输入且不回显，否则读取标准输入的全部内容，去掉结尾的换行，如
+  mts vault set payPwd < pwd.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		value, err := readVaultValue(args[0])
+		if err != nil {
+			return err
+		}
+		v.Set(args[0], value.Reveal())
+		return v.Save()
+	},
+}
+
+var vaultGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "输出条目的值",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		s, err := v.Get(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %v %s", internal.ErrConfig, err, args[0])
+		}
+		fmt.Println(s)
+		return nil
+	},
+}
+
+var vaultRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "删除条目",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		if err = v.Delete(args[0]); err != nil {
+			return fmt.Errorf("%w: %v %s", internal.ErrConfig, err, args[0])
+		}
+		return v.Save()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vaultCmd)
+	vaultCmd.AddCommand(vaultInitCmd, vaultSetCmd, vaultGetCmd, vaultRmCmd)
+}
+
+// vaultPath 返回--vault指定的文件，默认$HOME/.mts.vault
+func vaultPath() string {
+	if vaultFile != "" {
+		return vaultFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".mts.vault"
+	}
+	return filepath.Join(home, ".mts.vault")
+}
+
+// vaultPassphrase 读取口令，confirm为true时需要输入两次
+func vaultPassphrase(confirm bool) ([]byte, error) {
+	if p := os.Getenv("MTS_VAULT_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	p, err := promptSecret("请输入vault口令：")
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		p2, err := promptSecret("请再次输入vault口令：")
+		if err != nil {
+			return nil, err
+		}
+		if p != p2 {
+			return nil, fmt.Errorf("%w: 两次输入的口令不一致", internal.ErrConfig)
+		}
+	}
+	return []byte(p.Reveal()), nil
+}
+
+// openVault 打开并缓存vault，只需要输入一次口令
+func openVault() (*vault.Vault, error) {
+	if openedVault != nil {
+		return openedVault, nil
+	}
+	pass, err := vaultPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	v, err := vault.Open(vaultPath(), pass)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: vault文件不存在，请先执行 mts vault init", internal.ErrConfig)
+		}
+		return nil, fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	openedVault = v
+	return v, nil
+}
+
+// readVaultValue 读取条目的值，标准输入为终端时不回显输入
+func readVaultValue(name string) (logger.Secret, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return promptSecret(name + "：")
+	}
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", internal.ErrConfig, err)
+	}
+	value := strings.TrimRight(string(b), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%w: 标准输入为空", internal.ErrConfig)
+	}
+	return logger.NewSecret(value), nil
+}
+
+// resolveSecret 将vault:<name>引用替换为vault中的值，其他值原样返回，结果会注册为日志敏感值
+func resolveSecret(s string) (string, error) {
+	if !vault.IsRef(s) {
+		return s, nil
+	}
+	v, err := openVault()
+	if err != nil {
+		return "", err
+	}
+	value, err := v.Get(vault.RefName(s))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v %s", internal.ErrConfig, err, vault.RefName(s))
+	}
+	logger.RegisterSecret(value)
+	return value, nil
+}
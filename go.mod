@@ -8,4 +8,7 @@ require (
 	github.com/chromedp/chromedp v0.5.4
 	github.com/spf13/cobra v1.1.1
 	github.com/tidwall/gjson v1.6.7
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf
+	gopkg.in/yaml.v2 v2.4.0
 )
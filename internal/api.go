@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+// NewControlHandler return the local control api of e, every request must carry
+// "Authorization: Bearer <token>"
+//
+//	GET   /status  运行状态
+//	POST  /fire    立即开始抢购
+//	POST  /stop    停止抢购
+//	PATCH /config  修改works、num、strategy，只能在开始前修改
+//	GET   /log/level 当前日志等级
+//	PUT   /log/level 修改日志等级，如{"level":"INFO,chrome=WARN"}
+//	GET   /log/recent 最近的n条日志，follow=1时之后持续输出新日志，每行一条
+func NewControlHandler(e Engine, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "GET") {
+			return
+		}
+		writeJSON(w, http.StatusOK, e.Status())
+	})
+	mux.HandleFunc("/fire", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		e.Fire()
+		writeJSON(w, http.StatusAccepted, e.Status())
+	})
+	mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		e.Stop()
+		writeJSON(w, http.StatusAccepted, e.Status())
+	})
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "PATCH") {
+			return
+		}
+		var c EngineConfig
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := e.Configure(c); err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, ErrFired) {
+				code = http.StatusConflict
+			}
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, e.Status())
+	})
+	mux.HandleFunc("/log/level", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			var c struct {
+				Level string `json:"level"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := logger.SetLevel(c.Level); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			logger.Warn("日志等级已更新为", logger.Level())
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"level": logger.Level()})
+	})
+	mux.HandleFunc("/log/recent", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "GET") {
+			return
+		}
+		mem := logger.Memory()
+		if mem == nil {
+			writeError(w, http.StatusNotFound, errors.New("内存日志未开启"))
+			return
+		}
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		if n <= 0 {
+			n = 100
+		}
+		if r.URL.Query().Get("follow") == "" {
+			writeJSON(w, http.StatusOK, mem.Records(n))
+			return
+		}
+		followLogs(w, r, mem, n)
+	})
+	return guard(token, mux)
+}
+
+// guard 拒绝Host不是本机、没有token或body不是json的请求，
+// 避免浏览器中的网页通过跨域请求或DNS重绑定访问控制接口
+func guard(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, errors.New("Host必须是本机地址"))
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, nil)
+			return
+		}
+		switch r.Method {
+		case "POST", "PUT", "PATCH":
+			if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type必须是application/json"))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// followLogs 先输出最近n条日志，之后每行输出一条新日志，直到客户端断开
+func followLogs(w http.ResponseWriter, r *http.Request, mem *logger.MemoryLogger, n int) {
+	ch, cancel := mem.Subscribe(256)
+	defer cancel()
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, rec := range mem.Records(n) {
+		_ = enc.Encode(rec)
+	}
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case rec, ok := <-ch:
+			if !ok {
+				return
+			}
+			_ = enc.Encode(rec)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, nil)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	msg := http.StatusText(code)
+	if err != nil {
+		msg = err.Error()
+	}
+	writeJSON(w, code, map[string]string{"error": msg})
+}
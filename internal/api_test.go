@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	h := guard("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, c := range []struct {
+		method, host, auth, contentType string
+		code                            int
+	}{
+		{"GET", "127.0.0.1:9091", "Bearer secret", "", http.StatusNoContent},
+		{"GET", "localhost", "Bearer secret", "", http.StatusNoContent},
+		{"GET", "[::1]:9091", "Bearer secret", "", http.StatusNoContent},
+		{"POST", "127.0.0.1:9091", "Bearer secret", "application/json; charset=utf-8", http.StatusNoContent},
+		// DNS重绑定后Host为攻击者的域名
+		{"GET", "evil.example.com:9091", "Bearer secret", "", http.StatusForbidden},
+		{"GET", "127.0.0.1:9091", "", "", http.StatusUnauthorized},
+		{"GET", "127.0.0.1:9091", "Bearer secre", "", http.StatusUnauthorized},
+		// 跨域的简单请求不能带Authorization，也不能使用json的Content-Type
+		{"POST", "127.0.0.1:9091", "Bearer secret", "text/plain", http.StatusUnsupportedMediaType},
+		{"PATCH", "127.0.0.1:9091", "Bearer secret", "", http.StatusUnsupportedMediaType},
+	} {
+		r := httptest.NewRequest(c.method, "/fire", nil)
+		r.Host = c.host
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Fatalf("%+v: got %d", c, w.Code)
+		}
+	}
+}
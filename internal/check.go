@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chromedp/chromedp"
+	"github.com/oldthreefeng/mts/pkg/chrome"
+	"github.com/tidwall/gjson"
+)
+
+// 检查项的结果
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// 时钟偏差的误差超过该值时提示网络较慢，单位ms
+const checkMaxUncertainty = 100
+
+// CheckItem is one item of the pre-flight checklist
+type CheckItem struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Checklist 按检查顺序保存的结果
+type Checklist []CheckItem
+
+// Failed 返回未通过的检查项数
+func (c Checklist) Failed() int {
+	n := 0
+	for _, item := range c {
+		if item.Status == CheckFail {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Checklist) add(name, status, format string, v ...interface{}) {
+	*c = append(*c, CheckItem{Name: name, Status: status, Detail: fmt.Sprintf(format, v...)})
+}
+
+var titleRegexp = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+
+// Check 登陆后检查账号、商品、收货地址和时钟是否可以正常抢购，不提交订单；
+// 登陆失败或浏览器异常时返回错误
+func (jsk *jdSnap) Check() (list Checklist, err error) {
+	err = chromedp.Run(jsk.ctx, chromedp.Tasks{
+		jsk.InitActionFunc(),
+		chromedp.Navigate("https://passport.jd.com/uc/login"),
+		jsk.WaitLogin(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			jsk.checkUser(&list)
+			jsk.checkEidFp(&list)
+			jsk.checkSku(ctx, &list)
+			jsk.checkItemShowBtn(ctx, &list)
+			jsk.checkAddress(ctx, &list)
+			jsk.checkInitInfo(ctx, &list)
+			jsk.checkClock(&list)
+			return nil
+		}),
+	})
+	return list, err
+}
+
+func (jsk *jdSnap) checkUser(list *Checklist) {
+	jsk.mu.Lock()
+	name := jsk.UserInfo.Get("nickName").String()
+	jsk.mu.Unlock()
+	if name == "" {
+		list.add("登陆", CheckFail, "未获取到用户信息")
+		return
+	}
+	list.add("登陆", CheckPass, "用户 %s", name)
+}
+
+func (jsk *jdSnap) checkEidFp(list *Checklist) {
+	if jsk.eid != "" && jsk.fp != "" {
+		list.add("eid/fp", CheckPass, "已配置")
+		return
+	}
+	list.add("eid/fp", CheckWarn, "未配置，抢购前会在浏览器中自动获取，耗时较长，可通过--eid和--fp传入")
+}
+
+// checkSku 商品不存在时item.jd.com会重定向到其他页面
+func (jsk *jdSnap) checkSku(ctx context.Context, list *Checklist) {
+	req, _ := http.NewRequest("GET", "https://item.jd.com/"+jsk.SkuId+".html", nil)
+	req.Header.Add("User-Agent", jsk.userAgent)
+	resp, err := chrome.RequestByCookie(ctx, req, true)
+	if err != nil {
+		list.add("商品", CheckFail, "商品页请求失败 %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		list.add("商品", CheckFail, "sku %s 不存在，商品页返回 %d %s", jsk.SkuId, resp.StatusCode, resp.Header.Get("Location"))
+		return
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	title := ""
+	if m := titleRegexp.FindSubmatch(b); m != nil && utf8.Valid(m[1]) {
+		title = strings.TrimSpace(string(m[1]))
+	}
+	list.add("商品", CheckPass, "sku %s %s", jsk.SkuId, title)
+}
+
+func (jsk *jdSnap) checkItemShowBtn(ctx context.Context, list *Checklist) {
+	r, err := jsk.itemShowBtn(ctx)
+	if err != nil {
+		list.add("抢购按钮", CheckFail, "itemShowBtn请求失败 %v", err)
+		return
+	}
+	list.showBtn(r)
+}
+
+// showBtn 根据itemShowBtn的返回判断是否为秒杀商品
+func (list *Checklist) showBtn(r gjson.Result) {
+	if r.Get("url").String() != "" {
+		list.add("抢购按钮", CheckPass, "抢购链接已生成")
+		return
+	}
+	if r.Get("type").String() == "" {
+		list.add("抢购按钮", CheckFail, "不是秒杀商品：%s", r.Raw)
+		return
+	}
+	list.add("抢购按钮", CheckPass, "秒杀商品 type=%s state=%s，抢购链接在开始时生成", r.Get("type"), r.Get("state"))
+}
+
+// 商品页选择配送地址时使用的常用地址接口，不依赖秒杀信息
+const addressURL = "https://cd.jd.com/usual/address"
+
+// checkAddress 账号没有收货地址时无法提交订单
+func (jsk *jdSnap) checkAddress(ctx context.Context, list *Checklist) {
+	r, err := jsk.GetReq(addressURL, nil, "https://item.jd.com/"+jsk.SkuId+".html", ctx, true)
+	if err != nil && !errors.Is(err, ErrEmptyData) {
+		list.add("收货地址", CheckWarn, "收货地址查询失败 %v", err)
+		return
+	}
+	list.addresses(r)
+}
+
+// addresses 检查常用地址列表中是否有默认地址
+func (list *Checklist) addresses(r gjson.Result) {
+	addresses := r.Array()
+	if len(addresses) == 0 {
+		list.add("收货地址", CheckFail, "账号没有收货地址")
+		return
+	}
+	status, format, address := CheckWarn, "没有默认收货地址，将使用 %s", addresses[0]
+	for _, a := range addresses {
+		if a.Get("addressDefault").Bool() || a.Get("defaultAddress").Bool() {
+			status, format, address = CheckPass, "默认地址 %s", a
+			break
+		}
+	}
+	detail := address.Get("fullAddress").String()
+	if detail == "" {
+		detail = address.Get("addressDetail").String()
+	}
+	list.add("收货地址", status, format, strings.TrimSpace(address.Get("name").String()+" "+detail))
+}
+
+// checkInitInfo 秒杀信息一般在抢购开始后才能获取，获取失败只提示
+func (jsk *jdSnap) checkInitInfo(ctx context.Context, list *Checklist) {
+	skUrl := fmt.Sprintf("https://marathon.jd.com/seckill/seckill.action?skuId=%s&num=%d", jsk.SkuId, jsk.SecKillNum)
+	_, _ = jsk.GetReq(skUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", ctx, true)
+	if err := jsk.GetSecKillInitInfo(ctx); err != nil {
+		list.add("token", CheckWarn, "未获取到秒杀信息，通常在抢购开始后才能获取：%v", err)
+		return
+	}
+	list.initInfo(jsk.SecKillInfo)
+}
+
+// initInfo 检查秒杀信息中的token
+func (list *Checklist) initInfo(info gjson.Result) {
+	if info.Get("token").String() == "" {
+		list.add("token", CheckFail, "秒杀信息中没有token")
+		return
+	}
+	list.add("token", CheckPass, "已获取")
+}
+
+func (jsk *jdSnap) checkClock(list *Checklist) {
+	if err := jsk.SyncJdTime(); err != nil {
+		list.add("时钟", CheckFail, "同步京东服务器时间失败 %v", err)
+		return
+	}
+	status := CheckPass
+	if jsk.Uncertainty > checkMaxUncertainty {
+		status = CheckWarn
+	}
+	list.add("时钟", status, "时间差 %dms，误差±%dms", jsk.DiffTime, jsk.Uncertainty)
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestChecklistFailed(t *testing.T) {
+	for _, c := range []struct {
+		list Checklist
+		n    int
+	}{
+		{nil, 0},
+		{Checklist{{Status: CheckPass}, {Status: CheckWarn}}, 0},
+		{Checklist{{Status: CheckFail}, {Status: CheckPass}, {Status: CheckFail}}, 2},
+	} {
+		if n := c.list.Failed(); n != c.n {
+			t.Fatalf("%v: got %d, want %d", c.list, n, c.n)
+		}
+	}
+}
+
+func TestCheckShowBtn(t *testing.T) {
+	for _, c := range []struct {
+		body   string
+		status string
+		detail string
+	}{
+		{`{"url":"//divide.jd.com/user_routing?skuId=1"}`, CheckPass, "抢购链接已生成"},
+		{`{"type":"3","state":"12","url":""}`, CheckPass, "秒杀商品 type=3 state=12，抢购链接在开始时生成"},
+		{`{"state":"1"}`, CheckFail, `不是秒杀商品：{"state":"1"}`},
+	} {
+		var list Checklist
+		list.showBtn(gjson.Parse(c.body))
+		want := Checklist{{Name: "抢购按钮", Status: c.status, Detail: c.detail}}
+		if !reflect.DeepEqual(list, want) {
+			t.Fatalf("%s: got %v, want %v", c.body, list, want)
+		}
+	}
+}
+
+func TestCheckAddresses(t *testing.T) {
+	for _, c := range []struct {
+		body   string
+		status string
+		detail string
+	}{
+		{`[]`, CheckFail, "账号没有收货地址"},
+		{`null`, CheckFail, "账号没有收货地址"},
+		{`[{"name":"张三","fullAddress":"北京朝阳区"},{"name":"李四","fullAddress":"上海浦东新区","addressDefault":true}]`, CheckPass, "默认地址 李四 上海浦东新区"},
+		{`[{"name":"张三","addressDetail":"北京朝阳区"}]`, CheckWarn, "没有默认收货地址，将使用 张三 北京朝阳区"},
+	} {
+		var list Checklist
+		list.addresses(gjson.Parse(c.body))
+		want := Checklist{{Name: "收货地址", Status: c.status, Detail: c.detail}}
+		if !reflect.DeepEqual(list, want) {
+			t.Fatalf("%s: got %v, want %v", c.body, list, want)
+		}
+	}
+}
+
+func TestCheckInitInfo(t *testing.T) {
+	for _, c := range []struct {
+		body   string
+		status string
+	}{
+		{`{"token":"t","addressList":[]}`, CheckPass},
+		{`{"addressList":[]}`, CheckFail},
+	} {
+		var list Checklist
+		list.initInfo(gjson.Parse(c.body))
+		if len(list) != 1 || list[0].Name != "token" || list[0].Status != c.status {
+			t.Fatalf("%s: got %v", c.body, list)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/oldthreefeng/mts/pkg/utils"
+)
+
+// 运行阶段，由生命周期事件推导
+const (
+	PhaseInit      = "init"
+	PhaseLogin     = "login"
+	PhasePrepare   = "prepare"
+	PhaseWaiting   = "waiting"
+	PhaseFiring    = "firing"
+	PhaseSucceeded = "succeeded"
+	PhaseStopped   = "stopped"
+)
+
+// 重试策略
+const (
+	StrategyJitter = "jitter" // 失败后随机等待0-200ms再重试，默认
+	StrategyBurst  = "burst"  // 失败后立即重试
+)
+
+// ErrFired 已经开始抢购后不能再修改配置
+var ErrFired = errors.New("已开始抢购，无法修改配置")
+
+// Engine is implemented by jdSnap and tmSecKill, used by the control api
+type Engine interface {
+	Status() Status
+	// Fire 不再等待开始时间，立即开始抢购
+	Fire()
+	Stop()
+	// Configure 修改开始前的配置，nil字段不修改
+	Configure(c EngineConfig) error
+}
+
+// Status is the snapshot of a running engine
+type Status struct {
+	Platform         string    `json:"platform"`
+	SkuId            string    `json:"skuId"`
+	Phase            string    `json:"phase"`
+	ClockOffset      int64     `json:"clockOffsetMs"`
+	ClockUncertainty int64     `json:"clockUncertaintyMs"` // 时钟偏差的误差，0为未知
+	StartTime        time.Time `json:"startTime"`
+	CountdownMs      int64     `json:"countdownMs"`
+	Attempts         int64     `json:"attempts"`
+	OrderId          string    `json:"orderId,omitempty"`
+	Works            int       `json:"works"`
+	Num              int       `json:"num"`
+	Strategy         string    `json:"strategy"`
+	User             string    `json:"user,omitempty"`    // 登录的用户名
+	Address          string    `json:"address,omitempty"` // 下单使用的收货地址，生成订单参数后才有
+}
+
+// EngineConfig is the body of PATCH /config
+type EngineConfig struct {
+	Works    *int    `json:"works,omitempty"`
+	Num      *int    `json:"num,omitempty"`
+	Strategy *string `json:"strategy,omitempty"`
+}
+
+// validate 检查取值范围
+func (c EngineConfig) validate() error {
+	if c.Works != nil && *c.Works <= 0 {
+		return errors.New("works必须大于0")
+	}
+	if c.Num != nil && *c.Num <= 0 {
+		return errors.New("num必须大于0")
+	}
+	if c.Strategy != nil && *c.Strategy != StrategyJitter && *c.Strategy != StrategyBurst {
+		return errors.New("strategy只能为jitter或burst")
+	}
+	return nil
+}
+
+// trackPhase 根据事件更新运行阶段
+func (s *runStats) trackPhase(e Event) {
+	phase := ""
+	switch e.Type {
+	case EventLoginWaiting:
+		phase = PhaseLogin
+	case EventLoginOk:
+		phase = PhasePrepare
+	case EventWaiting:
+		phase = PhaseWaiting
+	case EventFireStarted:
+		phase = PhaseFiring
+	case EventOrderSucceeded:
+		phase = PhaseSucceeded
+	case EventStopped:
+		phase = PhaseStopped
+	default:
+		return
+	}
+	s.statsMu.Lock()
+	// 成功之后只允许进入停止阶段
+	if s.phase != PhaseSucceeded || phase == PhaseStopped {
+		s.phase = phase
+	}
+	s.statsMu.Unlock()
+}
+
+// Phase return the current phase
+func (s *runStats) Phase() string {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.phase == "" {
+		return PhaseInit
+	}
+	return s.phase
+}
+
+// retryDelay 按策略返回重试前的等待时间
+func retryDelay(strategy string) time.Duration {
+	if strategy == StrategyBurst {
+		return 0
+	}
+	return time.Duration(rand.Intn(200)) * time.Millisecond
+}
+
+// waitUntil 按服务器时间等待到st(毫秒)，fire被关闭时立即返回true，ctx结束时返回false
+func waitUntil(ctx context.Context, st int64, diffTime int64, fire <-chan struct{}) bool {
+	for {
+		d := utils.UnixMilli() - diffTime
+		if d >= st {
+			return true
+		}
+		// 最后4ms空转等待，保证精度
+		if st-d-4 <= 0 {
+			select {
+			case <-fire:
+				return true
+			case <-ctx.Done():
+				return false
+			default:
+			}
+			continue
+		}
+		t := time.NewTimer(time.Duration(st-d-4) * time.Millisecond)
+		select {
+		case <-fire:
+			t.Stop()
+			return true
+		case <-ctx.Done():
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+// 生命周期事件类型，字段名和取值保持稳定，供外部工具解析
+const (
+	EventLoginWaiting   = "login_waiting"
+	EventLoginOk        = "login_ok"
+	EventLoginExpired   = "login_expired"
+	EventEidFpOk        = "eid_fp_ok"
+	EventTimeSynced     = "time_synced"
+	EventWaiting        = "waiting"
+	EventFireStarted    = "fire_started"
+	EventAttempt        = "attempt"
+	EventOrderSucceeded = "order_succeeded"
+	EventStopped        = "stopped"
+)
+
+// attempt事件的阶段
+const (
+	StageItemShowBtn = "itemShowBtn"
+	StageSecKill     = "seckill.action"
+	StageInit        = "init"
+	StageSubmit      = "submit"
+)
+
+// attempt事件的结果
+const (
+	AttemptOk    = "ok"
+	AttemptEmpty = "empty"
+	AttemptFail  = "fail"
+	AttemptError = "error"
+)
+
+// Event is one lifecycle event of a run
+type Event struct {
+	Time             time.Time  `json:"time"`
+	Type             string     `json:"type"`
+	Platform         string     `json:"platform"`
+	SkuId            string     `json:"skuId"`
+	Worker           int        `json:"worker,omitempty"`
+	Stage            string     `json:"stage,omitempty"`
+	LatencyMs        float64    `json:"latencyMs,omitempty"`
+	Result           string     `json:"result,omitempty"`
+	OrderId          string     `json:"orderId,omitempty"`
+	ClockOffset      *int64     `json:"clockOffsetMs,omitempty"`
+	ClockUncertainty *int64     `json:"clockUncertaintyMs,omitempty"` // 时钟偏差的误差，为请求往返时间的一半
+	StartTime        *time.Time `json:"startTime,omitempty"`
+	ErrorClass       string     `json:"errorClass,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// EventBus 分发事件给所有订阅者，订阅者在发送事件的goroutine中同步执行
+type EventBus struct {
+	mu       sync.RWMutex
+	subs     []func(Event)
+	platform string
+	skuId    string
+}
+
+// NewEventBus return a bus which fills platform and skuId of every event
+func NewEventBus(platform, skuId string) *EventBus {
+	return &EventBus{platform: platform, skuId: skuId}
+}
+
+// Subscribe add fn to the subscribers
+func (b *EventBus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+// Emit send e to every subscriber
+func (b *EventBus) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Platform = b.platform
+	e.SkuId = b.skuId
+	recordEvent(e)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subs {
+		fn(e)
+	}
+}
+
+// NDJSONWriter return a subscriber which writes one json object per line to w
+func NDJSONWriter(w io.Writer) func(Event) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e Event) {
+		mu.Lock()
+		_ = enc.Encode(e)
+		mu.Unlock()
+	}
+}
+
+type workerKey struct{}
+
+// withWorker 将并发序号放入ctx，序号从1开始，ctx中的logger同时带上worker字段
+func withWorker(ctx context.Context, n int) context.Context {
+	ctx = logger.ContextWith(ctx, logger.KeyWorker, n)
+	return context.WithValue(ctx, workerKey{}, n)
+}
+
+func workerFrom(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	n, _ := ctx.Value(workerKey{}).(int)
+	return n
+}
+
+// attemptEvent 根据请求耗时和结果构造attempt事件
+func attemptEvent(ctx context.Context, stage string, begin time.Time, result string) Event {
+	return Event{
+		Type:      EventAttempt,
+		Worker:    workerFrom(ctx),
+		Stage:     stage,
+		LatencyMs: float64(time.Since(begin).Microseconds()) / 1e3,
+		Result:    result,
+	}
+}
+
+// sessionLogger 返回带有本次运行的session、平台和sku字段的logger
+func sessionLogger(platform, skuId string) *logger.LocalLogger {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return logger.With(logger.KeySession, hex.EncodeToString(b), logger.KeyPlatform, platform, logger.KeySku, skuId)
+}
+
+// logAttempt 返回以结构化字段记录每次请求的阶段、耗时和结果的订阅者
+func logAttempt(log *logger.LocalLogger) func(Event) {
+	return func(e Event) {
+		if e.Type != EventAttempt {
+			return
+		}
+		log.With(logger.KeyWorker, e.Worker, "stage", e.Stage, "latencyMs", e.LatencyMs, "result", e.Result).
+			Debug("请求完成")
+	}
+}
+
+// attemptResult 将请求错误归类为attempt结果
+func attemptResult(err error) string {
+	switch err {
+	case nil:
+		return AttemptOk
+	case ErrEmptyData:
+		return AttemptEmpty
+	}
+	return AttemptError
+}
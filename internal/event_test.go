@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	var b bytes.Buffer
+	bus := NewEventBus("jd", "100012043978")
+	bus.Subscribe(NDJSONWriter(&b))
+	offset := int64(-35)
+	bus.Emit(Event{Type: EventTimeSynced, ClockOffset: &offset})
+	bus.Emit(Event{Type: EventAttempt, Worker: 2, Stage: StageSubmit, LatencyMs: 83.215, Result: AttemptFail})
+	bus.Emit(Event{Type: EventStopped, ErrorClass: ClassTimeout, Error: ErrTimeout.Error()})
+
+	var events []map[string]interface{}
+	s := bufio.NewScanner(&b)
+	for s.Scan() {
+		var e map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", s.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d lines", len(events))
+	}
+	for _, e := range events {
+		if e["platform"] != "jd" || e["skuId"] != "100012043978" {
+			t.Fatalf("platform and skuId not filled %v", e)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, e["time"].(string)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events[0]["clockOffsetMs"] != -35.0 || events[1]["worker"] != 2.0 || events[1]["latencyMs"] != 83.215 ||
+		events[2]["errorClass"] != ClassTimeout {
+		t.Fatalf("unexpected events %v", events)
+	}
+	// 未设置的字段不输出
+	if _, ok := events[0]["worker"]; ok {
+		t.Fatalf("empty worker written %v", events[0])
+	}
+}
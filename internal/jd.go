@@ -8,10 +8,10 @@ import (
 	"math/rand"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/axgle/mahonia"
@@ -50,7 +50,24 @@ type jdSnap struct {
 	IsOk        bool
 	StartTime   time.Time
 	DiffTime    int64
-	PayPwd string
+	// Uncertainty DiffTime的误差，单位ms
+	Uncertainty int64
+	address     string
+	// PayPwd 支付密码，日志中不会输出
+	PayPwd logger.Secret
+	// Timeout 开始时间之后多久仍未抢到则退出，0为不限制
+	Timeout     time.Duration
+	Events      *EventBus
+	// Strategy 提交失败后的重试策略
+	Strategy    string
+	// log 带有session、平台和sku字段，ctx中的logger由此派生
+	log         *logger.LocalLogger
+	failChan    chan error
+	fireChan    chan struct{}
+	fireOnce    sync.Once
+	isFired     bool
+	isExpired   int32
+	runStats
 }
 
 // NewjdSnap is return 
@@ -68,19 +85,106 @@ func NewjdSnap(execPath string, skuId string, num, works int) *jdSnap {
 		Works:      works,
 		IsOk:       false,
 		IsOkChan:   make(chan struct{}, 1),
-	}
-	jsk.ctx, jsk.cancel = chrome.NewExecCtx(chromedp.ExecPath(execPath), chromedp.UserAgent(jsk.userAgent))
+		Events:     NewEventBus("jd", skuId),
+		Strategy:   StrategyJitter,
+		failChan:   make(chan error, 1),
+		fireChan:   make(chan struct{}),
+	}
+	jsk.log = sessionLogger("jd", skuId)
+	jsk.Events.Subscribe(jsk.trackPhase)
+	jsk.Events.Subscribe(logAttempt(jsk.log))
+	jsk.ctx, jsk.cancel = chrome.NewExecCtxWithLogger(jsk.log, chromedp.ExecPath(execPath), chromedp.UserAgent(jsk.userAgent))
+	jsk.ctx = logger.NewContext(jsk.ctx, jsk.log)
 	return jsk
 }
 
+// SetTransport 接口请求通过rt发送，用于录制请求，需在Run之前调用
+func (jsk *jdSnap) SetTransport(rt http.RoundTripper) {
+	jsk.ctx = chrome.WithTransport(jsk.ctx, rt)
+}
+
 func (jsk *jdSnap) SetEid(eid string) {
+	logger.RegisterSecret(eid)
 	jsk.eid = eid
 }
 
 func (jsk *jdSnap) SetFp(fp string) {
+	logger.RegisterSecret(fp)
 	jsk.fp = fp
 }
 
+// Result return the result document of this run
+func (jsk *jdSnap) Result() *Result {
+	jsk.mu.Lock()
+	diff := jsk.DiffTime
+	jsk.mu.Unlock()
+	return jsk.result("jd", jsk.SkuId, diff)
+}
+
+// Status implements Engine
+func (jsk *jdSnap) Status() Status {
+	jsk.mu.Lock()
+	works, num, strategy := jsk.Works, jsk.SecKillNum, jsk.Strategy
+	user, address := jsk.UserInfo.Get("nickName").String(), jsk.address
+	start, diff, unc := jsk.StartTime, jsk.DiffTime, jsk.Uncertainty
+	jsk.mu.Unlock()
+	return Status{
+		Platform:         "jd",
+		SkuId:            jsk.SkuId,
+		Phase:            jsk.Phase(),
+		ClockOffset:      diff,
+		ClockUncertainty: unc,
+		StartTime:        start,
+		CountdownMs:      start.UnixNano()/1e6 + diff - utils.UnixMilli(),
+		Attempts:         jsk.Attempts(),
+		OrderId:          jsk.Result().OrderId,
+		Works:            works,
+		Num:              num,
+		Strategy:         strategy,
+		User:             user,
+		Address:          address,
+	}
+}
+
+// Fire implements Engine
+func (jsk *jdSnap) Fire() {
+	jsk.fireOnce.Do(func() {
+		jsk.log.Warn("收到立即开始指令")
+		close(jsk.fireChan)
+	})
+}
+
+// Configure implements Engine
+func (jsk *jdSnap) Configure(c EngineConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	jsk.mu.Lock()
+	defer jsk.mu.Unlock()
+	if jsk.isFired {
+		return ErrFired
+	}
+	if c.Works != nil {
+		jsk.Works = *c.Works
+	}
+	if c.Num != nil {
+		jsk.SecKillNum = *c.Num
+	}
+	if c.Strategy != nil {
+		jsk.Strategy = *c.Strategy
+	}
+	jsk.log.Info("配置已更新：works", jsk.Works, "num", jsk.SecKillNum, "strategy", jsk.Strategy)
+	return nil
+}
+
+// fail 通知Run以err结束，只保留第一个错误
+func (jsk *jdSnap) fail(err error) {
+	select {
+	case jsk.failChan <- err:
+	default:
+	}
+}
+
 func (jsk *jdSnap) Stop() {
 	jsk.mu.Lock()
 	defer jsk.mu.Unlock()
@@ -100,6 +204,7 @@ func (jsk *jdSnap) GetReq(reqUrl string, params map[string]string, referer strin
 	if ctx == nil {
 		ctx = jsk.bCtx
 	}
+	log := logger.FromContext(ctx)
 	req, _ := http.NewRequest("GET", reqUrl, nil)
 	req.Header.Add("User-Agent", jsk.userAgent)
 	req.Header.Add("Referer", referer)
@@ -111,25 +216,32 @@ func (jsk *jdSnap) GetReq(reqUrl string, params map[string]string, referer strin
 		}
 		req.URL.RawQuery = q.Encode()
 	}
+	begin := time.Now()
 	resp, err := chrome.RequestByCookie(ctx, req, isDisableRedirects)
 	if err != nil {
+		observeRequest(req.URL.Path, 0, begin)
 		return gjson.Result{}, err
 	}
+	jsk.checkLogin(resp)
 	if resp.StatusCode != 200 {
-		logger.Info("httpCode: ", resp.StatusCode, "reqUrl: ", resp.Request.URL)
+		log.Info("httpCode: ", resp.StatusCode, "reqUrl: ", resp.Request.URL)
 	}
 	//设置cookie到浏览器
 	for _, respCookie := range resp.Cookies() {
+		if !chrome.HasBrowser(ctx) {
+			break
+		}
 		ok, err := network.SetCookie(respCookie.Name, respCookie.Value).WithURL(resp.Request.URL.String()).Do(ctx)
 		if !ok {
-			logger.Error(respCookie.Name, respCookie.Value, " cookie设置失败", err)
+			log.Error(respCookie.Name, " cookie设置失败", err)
 		}
 	}
 	defer resp.Body.Close()
 	b, _ := ioutil.ReadAll(resp.Body)
-	logger.Info("Get请求接口:", req.URL)
-	//	logger.Debug(string(b))
-	logger.Info("=======================")
+	observeRequest(req.URL.Path, resp.StatusCode, begin)
+	log.Info("Get请求接口:", req.URL)
+	//	log.Debug(string(b))
+	log.Info("=======================")
 	r := utils.FormatJsonpResponse(b, req.URL.String(), false)
 	if r.Raw == "null" || r.Raw == "" {
 		return gjson.Result{}, ErrEmptyData
@@ -137,25 +249,44 @@ func (jsk *jdSnap) GetReq(reqUrl string, params map[string]string, referer strin
 	return r, nil
 }
 
-func (jsk *jdSnap) SyncJdTime() {
+// checkLogin 请求被重定向到登陆页说明登陆已失效，只通知一次
+func (jsk *jdSnap) checkLogin(resp *http.Response) {
+	if !strings.Contains(resp.Header.Get("Location"), "passport.jd.com") &&
+		!strings.Contains(resp.Request.URL.Host, "passport.jd.com") {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&jsk.isExpired, 0, 1) {
+		jsk.log.Warn("登陆已失效，请求被重定向到登陆页：", resp.Request.URL)
+		jsk.Events.Emit(Event{Type: EventLoginExpired})
+	}
+}
+
+func (jsk *jdSnap) SyncJdTime() error {
+	begin := utils.UnixMilli()
 	resp, err := http.Get("https://a.jd.com//ajax/queryServerData.html")
 	if err != nil {
-		logger.Error(err)
-		os.Exit(0)
-		return
+		return err
 	}
+	end := utils.UnixMilli()
 	defer resp.Body.Close()
 	b, _ := ioutil.ReadAll(resp.Body)
 	r := gjson.ParseBytes(b)
 	jdTimeUnix := r.Get("serverTime").Int()
-	jsk.DiffTime = utils.UnixMilli() - jdTimeUnix
-	logger.Info("服务器与本地时间差为: ", jsk.DiffTime, "ms")
+	// 服务器时间对应请求往返的中点，误差不超过往返时间的一半
+	diff, unc := (begin+end)/2-jdTimeUnix, (end-begin+1)/2
+	jsk.mu.Lock()
+	jsk.DiffTime, jsk.Uncertainty = diff, unc
+	jsk.mu.Unlock()
+	jsk.log.Info("服务器与本地时间差为: ", diff, "ms，误差±", unc, "ms")
+	jsk.Events.Emit(Event{Type: EventTimeSynced, ClockOffset: &diff, ClockUncertainty: &unc})
+	return nil
 }
 
 func (jsk *jdSnap) PostReq(reqUrl string, params url.Values, referer string, ctx context.Context, isDisableRedirects bool) (gjson.Result, error) {
 	if ctx == nil {
 		ctx = jsk.bCtx
 	}
+	log := logger.FromContext(ctx)
 	req, _ := http.NewRequest("POST", reqUrl, strings.NewReader(params.Encode()))
 	req.Header.Add("User-Agent", jsk.userAgent)
 	if referer != "" {
@@ -163,23 +294,30 @@ func (jsk *jdSnap) PostReq(reqUrl string, params url.Values, referer string, ctx
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Host", req.URL.Host)
+	begin := time.Now()
 	resp, err := chrome.RequestByCookie(ctx, req, isDisableRedirects)
 	if err != nil {
+		observeRequest(req.URL.Path, 0, begin)
 		return gjson.Result{}, err
 	}
+	jsk.checkLogin(resp)
 
 	if resp.StatusCode != 200 {
-		logger.Warn("httpCode: ", resp.StatusCode, "reqUrl: ", resp.Request.URL)
+		log.Warn("httpCode: ", resp.StatusCode, "reqUrl: ", resp.Request.URL)
 	}
 	//设置cookie到浏览器
 	for _, respCookie := range resp.Cookies() {
+		if !chrome.HasBrowser(ctx) {
+			break
+		}
 		_, _ = network.SetCookie(respCookie.Name, respCookie.Value).WithURL(resp.Request.URL.String()).Do(ctx)
 	}
 	defer resp.Body.Close()
 	b, _ := ioutil.ReadAll(resp.Body)
+	observeRequest(req.URL.Path, resp.StatusCode, begin)
 
-	logger.Info("Post请求连接", req.URL)
-	logger.Info("=======================")
+	log.Info("Post请求连接", req.URL)
+	log.Info("=======================")
 	r := utils.FormatJsonpResponse(b, req.URL.String(), false)
 	if r.Raw == "null" || r.Raw == "" {
 		return gjson.Result{}, ErrEmptyData
@@ -222,7 +360,9 @@ func (jsk *jdSnap) InitActionFunc() chromedp.ActionFunc {
 					if strings.Contains(e.Response.URL, "passport.jd.com/user/petName/getUserInfoForMiniJd.action") {
 						b, err := network.GetResponseBody(e.RequestID).Do(ctx)
 						if err == nil {
+							jsk.mu.Lock()
 							jsk.UserInfo = FormatJdResponse(b, e.Response.URL, false)
+							jsk.mu.Unlock()
 						}
 						jsk.isLogin = true
 					}
@@ -234,106 +374,167 @@ func (jsk *jdSnap) InitActionFunc() chromedp.ActionFunc {
 	}
 }
 
-func (jsk *jdSnap) Run() error {
+func (jsk *jdSnap) Run() (err error) {
+	defer func() {
+		err = classifyStop(err)
+		jsk.Events.Emit(Event{Type: EventStopped, OrderId: jsk.Result().OrderId, ErrorClass: ErrorClass(err), Error: errString(err)})
+	}()
 	return chromedp.Run(jsk.ctx, chromedp.Tasks{
 		jsk.InitActionFunc(),
 		chromedp.Navigate("https://passport.jd.com/uc/login"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			logger.Info("等待登陆......")
-			for {
-				select {
-				case <-jsk.ctx.Done():
-					logger.Error("浏览器被关闭，退出进程")
-					return nil
-				case <-jsk.bCtx.Done():
-					logger.Error("浏览器被关闭，退出进程")
-					return nil
-				default:
-				}
-				if jsk.isLogin {
-					logger.Debug(jsk.UserInfo.Get("realName").String() + ", 登陆成功........")
-					break
-				}
-			}
-			return nil
-		}),
+		jsk.WaitLogin(),
 		jsk.GetEidAndFp(),
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			u := "https://item.jd.com/" + jsk.SkuId + ".html"
 			rand.Seed(time.Now().UnixNano())
 			_ = chromedp.Navigate(u).Do(ctx)
-			for i := 0; i < jsk.Works; i++ {
-				go func() {
-					jsk.WaitStart()
-					for {
-						jsk.FetchSecKillUrl()
-						logger.Info("正在访问抢购连接......")
-						_, err := jsk.GetReq(jsk.SecKillUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", jsk.bCtx, true)
-						//这里访问会响应302 禁止重定向后就会是空数据 所以这里空数据是正常的
-						if err == nil || err.Error() == ErrEmptyData.Error() {
-							break
-						}
-					}
-					SecKillRE:
-					//请求抢购连接，提交订单
-					err := jsk.ReqSubmitSecKillOrder(jsk.bCtx)
-					if err != nil {
-						logger.Info(err, "等待重试")
-						i := rand.Intn(200)
-						time.Sleep(time.Duration(i) * time.Millisecond)
-						goto SecKillRE
-					}
-					_ = chromedp.Navigate("https://order.jd.com/center/list.action").Do(jsk.bCtx)
-				}()
+			if !jsk.WaitStart(ctx) {
+				return stopped(jsk.ctx)
 			}
-			select {
-			case <-jsk.IsOkChan:
-				logger.Info("抢购成功。。。10s后关闭进程...")
-				_ = chromedp.Sleep(10 * time.Second).Do(ctx)
-			case <-jsk.ctx.Done():
-			case <-jsk.bCtx.Done():
+			if err := jsk.fire(ctx); err != nil {
+				return err
 			}
+			jsk.log.Info("抢购成功。。。10s后关闭进程...")
+			_ = chromedp.Sleep(10 * time.Second).Do(ctx)
 			return nil
 		}),
 	})
 }
 
-func (jsk *jdSnap) WaitStart() {
-	st := jsk.StartTime.UnixNano() / 1e6
-	logger.Info("等待时间到达" + jsk.StartTime.Format(utils.DateTimeFormatStr) + "...... 请勿关闭浏览器")
-	for {
-		select {
-		case <-jsk.ctx.Done():
-			logger.Error("浏览器被关闭，退出进程")
-			return
-		case <-jsk.bCtx.Done():
-			logger.Error("浏览器被关闭，退出进程")
-			return
-		default:
-		}
-		d := utils.UnixMilli()-jsk.DiffTime
-		if d >= st {
-			logger.Info("时间到达。。。。开始执行", time.Now().Format(utils.DateTimeFormatStr))
-			break
-		}
-		if st - d - 4 > 0 {
-			time.Sleep(time.Duration(st - d - 4) * time.Millisecond)
+// WaitLogin 等待在浏览器中登陆完成，浏览器被关闭时返回ErrLoginFailed
+func (jsk *jdSnap) WaitLogin() chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		jsk.log.Info("等待登陆......")
+		jsk.Events.Emit(Event{Type: EventLoginWaiting})
+		for {
+			select {
+			case <-jsk.ctx.Done():
+				jsk.log.Error("浏览器被关闭，退出进程")
+				return ErrLoginFailed
+			case <-jsk.bCtx.Done():
+				jsk.log.Error("浏览器被关闭，退出进程")
+				return ErrLoginFailed
+			default:
+			}
+			if jsk.isLogin {
+				if err := chrome.RegisterSessionCookies(ctx); err != nil {
+					jsk.log.Warn("会话cookie注册失败：", err)
+				}
+				jsk.log.Debug(jsk.UserInfo.Get("realName").String() + ", 登陆成功........")
+				jsk.Events.Emit(Event{Type: EventLoginOk})
+				break
+			}
 		}
+		return nil
+	}
+}
+
+// Replay 不启动浏览器，跳过登陆和获取eid、fp，直接从等待开始时间执行抢购流程，
+// ctx通过chrome.WithTransport将请求发送到回放服务
+func (jsk *jdSnap) Replay(ctx context.Context) (err error) {
+	defer func() {
+		err = classifyStop(err)
+		jsk.Events.Emit(Event{Type: EventStopped, OrderId: jsk.Result().OrderId, ErrorClass: ErrorClass(err), Error: errString(err)})
+	}()
+	ctx, cancel := context.WithCancel(logger.NewContext(ctx, jsk.log))
+	defer cancel()
+	jsk.bCtx = ctx
+	if !jsk.WaitStart(ctx) {
+		return stopped(ctx)
 	}
+	return jsk.fire(ctx)
+}
+
+// fire 启动Works个并发抢购，直到成功、售罄、超时或ctx结束，未下单时返回错误
+func (jsk *jdSnap) fire(ctx context.Context) error {
+	for i := 0; i < jsk.Works; i++ {
+		go func(wCtx context.Context) {
+			log := logger.FromContext(wCtx)
+			for {
+				if wCtx.Err() != nil {
+					return
+				}
+				jsk.FetchSecKillUrl(wCtx)
+				log.Info("正在访问抢购连接......")
+				_, err := jsk.GetReq(jsk.SecKillUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", wCtx, true)
+				//这里访问会响应302 禁止重定向后就会是空数据 所以这里空数据是正常的
+				if err == nil || err.Error() == ErrEmptyData.Error() {
+					break
+				}
+			}
+			SecKillRE:
+			if wCtx.Err() != nil {
+				return
+			}
+			//请求抢购连接，提交订单
+			jsk.markFire()
+			err := jsk.ReqSubmitSecKillOrder(wCtx)
+			if err != nil {
+				if errors.Is(err, ErrSoldOut) {
+					log.Warn(err)
+					jsk.fail(err)
+					return
+				}
+				log.Info(err, "等待重试")
+				time.Sleep(retryDelay(jsk.Strategy))
+				goto SecKillRE
+			}
+			if chrome.HasBrowser(jsk.bCtx) {
+				_ = chromedp.Navigate("https://order.jd.com/center/list.action").Do(jsk.bCtx)
+			}
+		}(withWorker(jsk.bCtx, i+1))
+	}
+	var timeout <-chan time.Time
+	if jsk.Timeout > 0 {
+		timeout = time.After(time.Until(jsk.StartTime) + jsk.Timeout)
+	}
+	select {
+	case <-jsk.IsOkChan:
+	case err := <-jsk.failChan:
+		return err
+	case <-timeout:
+		return ErrTimeout
+	case <-jsk.ctx.Done():
+		return stopped(jsk.ctx)
+	case <-ctx.Done():
+		return stopped(ctx)
+	}
+	return nil
+}
+
+// WaitStart 等待到开始时间或收到Fire，浏览器关闭时返回false
+func (jsk *jdSnap) WaitStart(ctx context.Context) bool {
+	st := jsk.StartTime.UnixNano() / 1e6
+	log := logger.FromContext(ctx)
+	log.Info("等待时间到达" + jsk.StartTime.Format(utils.DateTimeFormatStr) + "...... 请勿关闭浏览器")
+	startTime := jsk.StartTime
+	jsk.Events.Emit(Event{Type: EventWaiting, StartTime: &startTime})
+	if !waitUntil(ctx, st, jsk.DiffTime, jsk.fireChan) {
+		log.Error("浏览器被关闭，退出进程")
+		return false
+	}
+	jsk.mu.Lock()
+	jsk.isFired = true
+	jsk.mu.Unlock()
+	log.Info("时间到达。。。。开始执行", time.Now().Format(utils.DateTimeFormatStr))
+	jsk.Events.Emit(Event{Type: EventFireStarted})
+	return true
 }
 
 func (jsk *jdSnap) GetEidAndFp() chromedp.ActionFunc {
 	return func(ctx context.Context) error {
-		logger.Info(jsk.fp, jsk.eid)
+		log := logger.FromContext(ctx)
+		log.Info(jsk.fp, jsk.eid)
 		if jsk.eid != "" && jsk.fp != "" {
-			logger.Info("已传入eid与fp，程序将不再自动获取 ")
-			logger.Info("eid : ", jsk.eid, "fp : ", jsk.fp)
+			log.Info("已传入eid与fp，程序将不再自动获取 ")
+			log.Info("eid : ", jsk.eid, "fp : ", jsk.fp)
+			jsk.Events.Emit(Event{Type: EventEidFpOk})
 			return nil
 		}
 	RE:
-		logger.Info("正在获取eid和fp参数....")
+		log.Info("正在获取eid和fp参数....")
 		_ = chromedp.Navigate("https://search.jd.com/Search?keyword=衣服").Do(ctx)
-		logger.Info("等待页面更新完成....")
+		log.Info("等待页面更新完成....")
 		_ = chromedp.WaitVisible(".gl-item").Do(ctx)
 		var itemNodes []*cdp.Node
 		err := chromedp.Nodes(".gl-item", &itemNodes, chromedp.ByQueryAll).Do(ctx)
@@ -344,7 +545,7 @@ func (jsk *jdSnap) GetEidAndFp() chromedp.ActionFunc {
 		_ = dom.ScrollIntoViewIfNeeded().WithNodeID(n.NodeID).Do(ctx)
 		_, _, _, _ = page.Navigate("https://item.jd.com/" + n.AttributeValue("data-sku") + ".html").Do(ctx)
 
-		logger.Info("等待商品详情页更新完成....")
+		log.Info("等待商品详情页更新完成....")
 		_ = chromedp.WaitVisible("#InitCartUrl").Do(ctx)
 		_ = chromedp.Sleep(1 * time.Second).Do(ctx)
 		_ = chromedp.Click("#InitCartUrl").Do(ctx)
@@ -353,16 +554,16 @@ func (jsk *jdSnap) GetEidAndFp() chromedp.ActionFunc {
 		_ = chromedp.Click("#GotoShoppingCart").Do(ctx)
 		//_ = chromedp.Navigate("https://cart.jd.com/cart_index/").Do(ctx)
 		ch, cc := chrome.WaitDocumentUpdated(ctx)
-		logger.Info("等待购物车页面.....")
+		log.Info("等待购物车页面.....")
 		<-ch
 		cc()
 		info, _ := target.GetTargetInfo().Do(ctx)
 		if strings.Contains(info.URL, "cart.jd.com/cart_index") {
-			logger.Info("Click, common-submit-btn")
+			log.Info("Click, common-submit-btn")
 			_ = chromedp.Sleep(1 * time.Second).Do(ctx);
 			_ = chromedp.Click(".common-submit-btn").Do(ctx)
 		} else {
-			logger.Info("Click, submit-btn")
+			log.Info("Click, submit-btn")
 			_ = chromedp.WaitVisible("container", chromedp.ByID).Do(ctx)
 			_ = chromedp.ScrollIntoView(".submit-btn").Do(ctx);
 			_ = chromedp.Sleep(1 * time.Second).Do(ctx);
@@ -371,48 +572,58 @@ func (jsk *jdSnap) GetEidAndFp() chromedp.ActionFunc {
 
 		//_ = chromedp.WaitVisible("#mainframe").Do(ctx)
 		ch, cc = chrome.WaitDocumentUpdated(ctx)
-		logger.Info("等待结算页加载完成..... 如遇到未选中商品错误，可手动选中后点击结算")
+		log.Info("等待结算页加载完成..... 如遇到未选中商品错误，可手动选中后点击结算")
 		<-ch
 		cc()
 		//执行js参数 将eid和fp显示到对应元素上
 		_ = chromedp.Sleep(3 * time.Second).Do(ctx)
 		res := make(map[string]interface{})
 		err = chromedp.Evaluate("_JdTdudfp", &res).Do(ctx)
-		logger.Error(err)
-		logger.Info("_JdTdudfp: ", res)
+		log.Error(err)
+		for _, k := range []string{"eid", "fp"} {
+			if v, ok := res[k].(string); ok {
+				logger.RegisterSecret(v)
+			}
+		}
+		log.Info("_JdTdudfp: ", res)
 		eid, ok := res["eid"]
 		if !ok {
-			logger.Info("获取eid失败,正在重试")
+			log.Info("获取eid失败,正在重试")
 			goto RE
 		}
 		jsk.eid = eid.(string)
 		jsk.fp = res["fp"].(string)
 
 		if jsk.fp == "" || jsk.eid == "" || jsk.fp == "undefined" || jsk.eid == "undefined" {
-			logger.Warn("获取参数失败，等待重试。。。 重试过程过久可手动刷新浏览器")
+			log.Warn("获取参数失败，等待重试。。。 重试过程过久可手动刷新浏览器")
 			goto RE
 		}
-		logger.Info("参数获取成功：eid【" + jsk.eid + "】, fp【" + jsk.fp + "】")
+		log.Info("参数获取成功：eid【" + jsk.eid + "】, fp【" + jsk.fp + "】")
+		jsk.Events.Emit(Event{Type: EventEidFpOk})
 
 		return nil
 	}
 }
 
-func (jsk *jdSnap) FetchSecKillUrl() {
+func (jsk *jdSnap) FetchSecKillUrl(ctx context.Context) {
 	/*jsk.SecKillUrl = "https://marathon.jd.com/captcha.html?skuId="+jsk.SkuId+"&sn=c3f4ececd8461f0e4d7267e96a91e0e0&from=pc"
 	return*/
-	logger.Info("开始获取抢购连接.....")
+	log := logger.FromContext(ctx)
+	log.Info("开始获取抢购连接.....")
 	for {
 		if jsk.SecKillUrl != "" {
 			break
 		}
-		jsk.SecKillUrl = jsk.GetSecKillUrl()
-		logger.Warn("抢购链接获取失败.....正在重试")
+		if ctx.Err() != nil {
+			return
+		}
+		jsk.SecKillUrl = jsk.GetSecKillUrl(ctx)
+		log.Warn("抢购链接获取失败.....正在重试")
 	}
 	jsk.SecKillUrl = "https:" + strings.TrimPrefix(jsk.SecKillUrl, "https:")
 	jsk.SecKillUrl = strings.ReplaceAll(jsk.SecKillUrl, "divide", "marathon")
 	jsk.SecKillUrl = strings.ReplaceAll(jsk.SecKillUrl, "user_routing", "captcha.html")
-	logger.Debug("抢购连接获取成功....", jsk.SecKillUrl)
+	log.Debug("抢购连接获取成功....", jsk.SecKillUrl)
 	return
 }
 
@@ -420,47 +631,63 @@ func (jsk *jdSnap) ReqSubmitSecKillOrder(ctx context.Context) error {
 	if ctx == nil {
 		ctx = jsk.bCtx
 	}
+	log := logger.FromContext(ctx)
 
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Error(r)
+			log.Error(r)
 		}
 	}()
 	//这里修改为直接使用http请求访问抢购结算页面 提高速度
 	skUrl := fmt.Sprintf("https://marathon.jd.com/seckill/seckill.action?skuId=%s&num=%d&rid=%d", jsk.SkuId, jsk.SecKillNum, time.Now().Unix())
-	logger.Info("访问抢购订单结算页面......", skUrl)
-	_, _ = jsk.GetReq(skUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", ctx, true)
+	log.Info("访问抢购订单结算页面......", skUrl)
+	begin := time.Now()
+	_, err := jsk.GetReq(skUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", ctx, true)
+	jsk.Events.Emit(attemptEvent(ctx, StageSecKill, begin, attemptResult(err)))
 
 	//这里直接使用浏览器跳转 主要目的是获取cookie
 	/*jsk.GetReq(skUrl, nil, "https://item.jd.com/"+jsk.SkuId+".html", ctx)
 	_, _, _, _ = page.Navigate(skUrl).WithReferrer("https://item.jd.com/"+jsk.SkuId+".html").Do(ctx)*/
 
-	logger.Info("获取抢购信息...............")
-	err := jsk.GetSecKillInitInfo(ctx)
+	log.Info("获取抢购信息...............")
+	begin = time.Now()
+	err = jsk.GetSecKillInitInfo(ctx)
+	jsk.Events.Emit(attemptEvent(ctx, StageInit, begin, attemptResult(err)))
 	if err != nil {
-		logger.Error("抢购失败：", err, "正在重试.......")
+		log.Error("抢购失败：", err, "正在重试.......")
 		return err
 	}
 
-	orderData := jsk.GetOrderReqData()
+	orderData := jsk.GetOrderReqData(ctx)
 
 	if len(orderData) == 0 {
 		return errors.New("订单参数生成失败")
 	}
-	logger.Info("订单参数：", orderData.Encode())
-	logger.Info("提交抢购订单.............")
+	log.Debug("订单参数：", maskValues(orderData, "password", "eid", "fp", "mobileKey", "invoicePhoneKey").Encode())
+	log.Info("提交抢购订单.............")
 
+	begin = time.Now()
 	r, err := jsk.PostReq("https://marathon.jd.com/seckillnew/orderService/pc/submitOrder.action?skuId="+jsk.SkuId+"", orderData, skUrl, ctx, false)
+	submitEvent := attemptEvent(ctx, StageSubmit, begin, attemptResult(err))
 	if err != nil {
-		logger.Error("订单提交失败，正在重新提交.....", " errMsg => ", err, " raw => ", r.Raw)
+		jsk.Events.Emit(submitEvent)
+		log.Error("订单提交失败，正在重新提交.....", " errMsg => ", err, " raw => ", r.Raw)
 		return err
 	}
 	orderId := r.Get("orderId").String()
 	if orderId != "" && orderId != "0" {
+		jsk.Events.Emit(submitEvent)
+		jsk.Events.Emit(Event{Type: EventOrderSucceeded, Worker: workerFrom(ctx), OrderId: orderId})
+		jsk.setOrderId(orderId)
 		jsk.IsOk = true
 		jsk.IsOkChan <- struct{}{}
-		logger.Info("抢购成功，订单编号:", r.Get("orderId").String())
+		log.Info("抢购成功，订单编号:", r.Get("orderId").String())
 	} else {
+		submitEvent.Result = AttemptFail
+		jsk.Events.Emit(submitEvent)
+		if msg := r.Get("errorMessage").String(); isSoldOut(msg) {
+			return fmt.Errorf("%w: %s", ErrSoldOut, msg)
+		}
 		if r.IsObject() || r.IsArray() {
 			return errors.New("抢购失败：" + r.Raw)
 		}
@@ -469,11 +696,38 @@ func (jsk *jdSnap) ReqSubmitSecKillOrder(ctx context.Context) error {
 	return nil
 }
 
-func (jsk *jdSnap) GetOrderReqData() url.Values {
-	logger.Info("生成订单所需参数...")
+// 提交订单返回的售罄提示
+var soldOutKeywords = []string{"售完", "抢光", "无货", "库存不足"}
+
+func isSoldOut(msg string) bool {
+	for _, k := range soldOutKeywords {
+		if strings.Contains(msg, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskValues 复制v并屏蔽keys的值，用于输出日志
+func maskValues(v url.Values, keys ...string) url.Values {
+	m := make(url.Values, len(v))
+	for k, vs := range v {
+		m[k] = vs
+	}
+	for _, k := range keys {
+		if m.Get(k) != "" {
+			m.Set(k, logger.SecretMask)
+		}
+	}
+	return m
+}
+
+func (jsk *jdSnap) GetOrderReqData(ctx context.Context) url.Values {
+	log := logger.FromContext(ctx)
+	log.Info("生成订单所需参数...")
 	defer func() {
 		if f := recover(); f != nil {
-			logger.Error("订单参数错误：", f)
+			log.Error("订单参数错误：", f)
 		}
 	}()
 
@@ -481,14 +735,17 @@ func (jsk *jdSnap) GetOrderReqData() url.Values {
 	var defaultAddress gjson.Result
 	for _, dAddress := range addressList {
 		if dAddress.Get("defaultAddress").Bool() {
-			logger.Info("获取到默认收货地址")
+			log.Info("获取到默认收货地址")
 			defaultAddress = dAddress
 		}
 	}
 	if defaultAddress.Raw == "" {
-		logger.Info("没有获取到默认收货地址， 自动选择一个地址")
+		log.Info("没有获取到默认收货地址， 自动选择一个地址")
 		defaultAddress = addressList[0]
 	}
+	jsk.mu.Lock()
+	jsk.address = strings.TrimSpace(defaultAddress.Get("name").String() + " " + defaultAddress.Get("addressDetail").String())
+	jsk.mu.Unlock()
 	invoiceInfo := jsk.SecKillInfo.Get("invoiceInfo")
 	r := url.Values{
 		"skuId":              []string{jsk.SkuId},
@@ -514,7 +771,7 @@ func (jsk *jdSnap) GetOrderReqData() url.Values {
 		"invoicePhone":       []string{invoiceInfo.Get("invoicePhone").String()},
 		"invoicePhoneKey":    []string{invoiceInfo.Get("invoicePhoneKey").String()},
 		"invoice":            []string{"true"},
-		"password":           []string{jsk.PayPwd},
+		"password":           []string{jsk.PayPwd.Reveal()},
 		"codTimeType":        []string{"3"},
 		"paymentType":        []string{"4"},
 		"areaCode":           []string{""},
@@ -558,16 +815,23 @@ func (jsk *jdSnap) GetSecKillInitInfo(ctx context.Context) error {
 		return err
 	}
 	jsk.SecKillInfo = r
-	logger.Info("秒杀信息获取成功：", jsk.SecKillInfo.Raw)
+	logger.FromContext(ctx).Info("秒杀信息获取成功：", jsk.SecKillInfo.Raw)
 	return nil
 }
 
-func (jsk *jdSnap) GetSecKillUrl() string {
-	r, _ := jsk.GetReq("https://itemko.jd.com/itemShowBtn", map[string]string{
+func (jsk *jdSnap) GetSecKillUrl(ctx context.Context) string {
+	begin := time.Now()
+	r, err := jsk.itemShowBtn(ctx)
+	jsk.Events.Emit(attemptEvent(ctx, StageItemShowBtn, begin, attemptResult(err)))
+	return r.Get("url").String()
+}
+
+// itemShowBtn 查询商品的抢购按钮状态，开始前返回的url为空
+func (jsk *jdSnap) itemShowBtn(ctx context.Context) (gjson.Result, error) {
+	return jsk.GetReq("https://itemko.jd.com/itemShowBtn", map[string]string{
 		"callback": "jQuery" + strconv.FormatInt(utils.GenerateRangeNum(1000000, 9999999), 10),
 		"skuId":    jsk.SkuId,
 		"from":     "pc",
 		"_":        strconv.FormatInt(time.Now().Unix()*1000, 10),
-	}, "https://item.jd.com/"+jsk.SkuId+".html", nil, false)
-	return r.Get("url").String()
+	}, "https://item.jd.com/"+jsk.SkuId+".html", ctx, false)
 }
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/oldthreefeng/mts/pkg/metrics"
+)
+
+var (
+	attemptsTotal = metrics.NewCounterVec("mts_attempts_total",
+		"Number of seckill attempts per stage and result.", "platform", "stage", "result")
+	requestsTotal = metrics.NewCounterVec("mts_http_requests_total",
+		"Number of http requests per endpoint and status code.", "endpoint", "code")
+	requestDuration = metrics.NewHistogramVec("mts_request_duration_seconds",
+		"Latency of http requests per endpoint.", metrics.DefBuckets, "endpoint")
+	clockOffset = metrics.NewGaugeVec("mts_clock_offset_seconds",
+		"Local clock minus server clock.", "platform")
+	loggedIn = metrics.NewGaugeVec("mts_logged_in",
+		"1 if the session is logged in.", "platform")
+
+	// startUnixNano 开始时间，offsetMs 本地时间减服务器时间，用于计算距离开始的秒数
+	startUnixNano int64
+	offsetMs      int64
+)
+
+func init() {
+	metrics.MustRegister(attemptsTotal, requestsTotal, requestDuration, clockOffset, loggedIn,
+		metrics.NewGaugeFunc("mts_time_to_start_seconds", "Seconds until the start time by the server clock, negative after start.", timeToStart))
+}
+
+// timeToStart 按服务器时间计算距离开始的秒数，与Status的CountdownMs一致
+func timeToStart() float64 {
+	st := atomic.LoadInt64(&startUnixNano)
+	if st == 0 {
+		return 0
+	}
+	return (time.Until(time.Unix(0, st)) + time.Duration(atomic.LoadInt64(&offsetMs))*time.Millisecond).Seconds()
+}
+
+// recordEvent 根据事件更新指标
+func recordEvent(e Event) {
+	switch e.Type {
+	case EventAttempt:
+		attemptsTotal.WithLabelValues(e.Platform, e.Stage, e.Result).Inc()
+	case EventTimeSynced:
+		if e.ClockOffset != nil {
+			clockOffset.WithLabelValues(e.Platform).Set(float64(*e.ClockOffset) / 1e3)
+			atomic.StoreInt64(&offsetMs, *e.ClockOffset)
+		}
+	case EventLoginOk:
+		loggedIn.WithLabelValues(e.Platform).Set(1)
+	case EventLoginExpired, EventLoginWaiting:
+		loggedIn.WithLabelValues(e.Platform).Set(0)
+	case EventWaiting:
+		if e.StartTime != nil {
+			atomic.StoreInt64(&startUnixNano, e.StartTime.UnixNano())
+		}
+	}
+}
+
+// observeRequest 记录一次http请求，code为0表示请求失败
+func observeRequest(reqPath string, code int, begin time.Time) {
+	endpoint := path.Base(reqPath)
+	status := "error"
+	if code > 0 {
+		status = strconv.Itoa(code)
+	}
+	requestsTotal.WithLabelValues(endpoint, status).Inc()
+	requestDuration.WithLabelValues(endpoint).Observe(time.Since(begin).Seconds())
+}
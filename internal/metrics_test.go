@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeToStart(t *testing.T) {
+	defer func() { startUnixNano, offsetMs = 0, 0 }()
+	if v := timeToStart(); v != 0 {
+		t.Fatalf("before waiting: %v", v)
+	}
+	start := time.Now().Add(10 * time.Second)
+	recordEvent(Event{Type: EventWaiting, StartTime: &start})
+	// 本地时钟快2s，按服务器时间还要多等2s
+	offset := int64(2000)
+	recordEvent(Event{Type: EventTimeSynced, Platform: "jd", ClockOffset: &offset})
+	if v := timeToStart(); math.Abs(v-12) > 0.5 {
+		t.Fatalf("got %v, want about 12", v)
+	}
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 运行结束时的错误分类，cmd根据分类返回不同的退出码
+var (
+	ErrSoldOut         = errors.New("商品已售罄")
+	ErrLoginFailed     = errors.New("登陆失败")
+	ErrBrowserNotFound = errors.New("浏览器执行路径未找到")
+	ErrTimeout         = errors.New("抢购超时")
+	ErrConfig          = errors.New("配置错误")
+	ErrCheckFailed     = errors.New("检查未通过")
+	ErrStopped         = errors.New("未下单即停止")
+)
+
+// 错误分类名称，用于结果文件的errorClass字段
+const (
+	ClassSoldOut         = "sold_out"
+	ClassLoginFailed     = "login_failed"
+	ClassBrowserNotFound = "browser_not_found"
+	ClassTimeout         = "timeout"
+	ClassConfig          = "config_error"
+	ClassCheckFailed     = "check_failed"
+	ClassStopped         = "stopped"
+	ClassUnknown         = "unknown"
+)
+
+// ErrorClass return the class name of err, empty if err is nil
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrSoldOut):
+		return ClassSoldOut
+	case errors.Is(err, ErrLoginFailed):
+		return ClassLoginFailed
+	case errors.Is(err, ErrBrowserNotFound):
+		return ClassBrowserNotFound
+	case errors.Is(err, ErrTimeout):
+		return ClassTimeout
+	case errors.Is(err, ErrConfig):
+		return ClassConfig
+	case errors.Is(err, ErrCheckFailed):
+		return ClassCheckFailed
+	case errors.Is(err, ErrStopped):
+		return ClassStopped
+	}
+	return ClassUnknown
+}
+
+// stopped 返回ctx结束导致未下单时的错误，超时为ErrTimeout，其他为ErrStopped
+func stopped(ctx context.Context) error {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	case err != nil:
+		return fmt.Errorf("%w: %v", ErrStopped, err)
+	}
+	return ErrStopped
+}
+
+// classifyStop 将浏览器或ctx被取消返回的未分类错误归为ErrStopped
+func classifyStop(err error) error {
+	if ErrorClass(err) == ClassUnknown && errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %v", ErrStopped, err)
+	}
+	return err
+}
+
+// Result is the final document of a run
+type Result struct {
+	Platform      string     `json:"platform"`
+	SkuId         string     `json:"skuId"`
+	OrderId       string     `json:"orderId,omitempty"`
+	Attempts      int64      `json:"attempts"`
+	FirstFireTime *time.Time `json:"firstFireTime,omitempty"`
+	LastFireTime  *time.Time `json:"lastFireTime,omitempty"`
+	ClockOffset   int64      `json:"clockOffsetMs"`
+	ErrorClass    string     `json:"errorClass,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// SetErr fill the error fields of r
+func (r *Result) SetErr(err error) {
+	r.ErrorClass = ErrorClass(err)
+	r.Error = errString(err)
+}
+
+// runStats 记录抢购过程中的请求次数和时间，jd与tm共用
+type runStats struct {
+	attempts  int64
+	statsMu   sync.Mutex
+	firstFire time.Time
+	lastFire  time.Time
+	orderId   string
+	phase     string
+}
+
+// markFire 每次提交订单前调用
+func (s *runStats) markFire() {
+	atomic.AddInt64(&s.attempts, 1)
+	now := time.Now()
+	s.statsMu.Lock()
+	if s.firstFire.IsZero() {
+		s.firstFire = now
+	}
+	s.lastFire = now
+	s.statsMu.Unlock()
+}
+
+func (s *runStats) setOrderId(id string) {
+	s.statsMu.Lock()
+	s.orderId = id
+	s.statsMu.Unlock()
+}
+
+// Attempts return the number of order submits
+func (s *runStats) Attempts() int64 {
+	return atomic.LoadInt64(&s.attempts)
+}
+
+func (s *runStats) result(platform, skuId string, diffTime int64) *Result {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	r := &Result{
+		Platform:    platform,
+		SkuId:       skuId,
+		OrderId:     s.orderId,
+		Attempts:    atomic.LoadInt64(&s.attempts),
+		ClockOffset: diffTime,
+	}
+	if !s.firstFire.IsZero() {
+		first, last := s.firstFire, s.lastFire
+		r.FirstFireTime = &first
+		r.LastFireTime = &last
+	}
+	return r
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
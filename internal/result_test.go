@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	for _, c := range []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{errors.New("x"), ClassUnknown},
+		{context.Canceled, ClassUnknown},
+		{fmt.Errorf("%w: 已售完", ErrSoldOut), ClassSoldOut},
+		{ErrLoginFailed, ClassLoginFailed},
+		{ErrBrowserNotFound, ClassBrowserNotFound},
+		{ErrTimeout, ClassTimeout},
+		{ErrConfig, ClassConfig},
+		{ErrCheckFailed, ClassCheckFailed},
+		{ErrStopped, ClassStopped},
+	} {
+		if class := ErrorClass(c.err); class != c.class {
+			t.Fatalf("%v: got %q, want %q", c.err, class, c.class)
+		}
+	}
+}
+
+func TestStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if !errors.Is(stopped(ctx), ErrStopped) {
+		t.Fatal("running ctx not classified as stopped")
+	}
+	cancel()
+	if err := stopped(ctx); !errors.Is(err, ErrStopped) || ErrorClass(err) != ClassStopped {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if err := stopped(ctx); ErrorClass(err) != ClassTimeout {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := classifyStop(fmt.Errorf("chromedp: %w", context.Canceled)); ErrorClass(err) != ClassStopped {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 已分类的错误和nil不变
+	for _, err := range []error{nil, ErrSoldOut, fmt.Errorf("%w: %v", ErrLoginFailed, context.Canceled)} {
+		if classifyStop(err) != err {
+			t.Fatalf("%v reclassified", err)
+		}
+	}
+}
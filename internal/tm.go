@@ -35,6 +35,18 @@ type tmSecKill struct {
 	StartTime  time.Time
 	DiffTime   int64
 	IsSyncTime bool
+	// Timeout 开始时间之后多久仍未抢到则退出，0为不限制
+	Timeout    time.Duration
+	Events     *EventBus
+	// Strategy 提交失败后的重试策略
+	Strategy   string
+	// log 带有session、平台和sku字段，ctx中的logger由此派生
+	log        *logger.LocalLogger
+	fireChan   chan struct{}
+	fireOnce   sync.Once
+	isFired    bool
+	isOpened   bool
+	runStats
 }
 
 func NewTmSecKill(execPath string, skuId string, num, works int) *tmSecKill {
@@ -56,9 +68,15 @@ func NewTmSecKill(execPath string, skuId string, num, works int) *tmSecKill {
 		DiffTime:   0,
 		isClose:    false,
 		IsSyncTime: false,
+		Events:     NewEventBus("tm", skuId),
+		Strategy:   StrategyJitter,
+		fireChan:   make(chan struct{}),
 	}
-	c, cc := chrome.NewExecCtx(chromedp.ExecPath(execPath), chromedp.UserAgent(tsk.userAgent))
-	tsk.ctx = NewContextStruct(c, cc, "")
+	tsk.log = sessionLogger("tm", skuId)
+	tsk.Events.Subscribe(tsk.trackPhase)
+	tsk.Events.Subscribe(logAttempt(tsk.log))
+	c, cc := chrome.NewExecCtxWithLogger(tsk.log, chromedp.ExecPath(execPath), chromedp.UserAgent(tsk.userAgent))
+	tsk.ctx = NewContextStruct(logger.NewContext(c, tsk.log), cc, "")
 	return tsk
 }
 
@@ -74,6 +92,68 @@ func (tsk *tmSecKill) Stop() {
 	return
 }
 
+// Status implements Engine
+func (tsk *tmSecKill) Status() Status {
+	tsk.mu.Lock()
+	works, num, strategy := tsk.Works, tsk.SecKillNum, tsk.Strategy
+	start, diff := tsk.StartTime, tsk.DiffTime
+	tsk.mu.Unlock()
+	return Status{
+		Platform:    "tm",
+		SkuId:       tsk.SkuId,
+		Phase:       tsk.Phase(),
+		ClockOffset: diff,
+		StartTime:   start,
+		CountdownMs: start.UnixNano()/1e6 + diff - utils.UnixMilli(),
+		Attempts:    tsk.Attempts(),
+		Works:       works,
+		Num:         num,
+		Strategy:    strategy,
+	}
+}
+
+// Fire implements Engine
+func (tsk *tmSecKill) Fire() {
+	tsk.fireOnce.Do(func() {
+		tsk.log.Warn("收到立即开始指令")
+		close(tsk.fireChan)
+	})
+}
+
+// Configure implements Engine, works只能在打开抢购标签前修改
+func (tsk *tmSecKill) Configure(c EngineConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	tsk.mu.Lock()
+	defer tsk.mu.Unlock()
+	if tsk.isFired {
+		return ErrFired
+	}
+	if c.Works != nil && tsk.isOpened {
+		return errors.New("抢购标签已打开，无法修改works")
+	}
+	if c.Works != nil {
+		tsk.Works = *c.Works
+	}
+	if c.Num != nil {
+		tsk.SecKillNum = *c.Num
+	}
+	if c.Strategy != nil {
+		tsk.Strategy = *c.Strategy
+	}
+	tsk.log.Info("配置已更新：works", tsk.Works, "num", tsk.SecKillNum, "strategy", tsk.Strategy)
+	return nil
+}
+
+// Result return the result document of this run
+func (tsk *tmSecKill) Result() *Result {
+	tsk.mu.Lock()
+	diff := tsk.DiffTime
+	tsk.mu.Unlock()
+	return tsk.result("tm", tsk.SkuId, diff)
+}
+
 //初始化监听请求数据
 func (tsk *tmSecKill) InitActionFunc() chromedp.ActionFunc {
 	return func(ctx context.Context) error {
@@ -98,11 +178,15 @@ func (tsk *tmSecKill) InitActionFunc() chromedp.ActionFunc {
 						r := gjson.ParseBytes(b)
 						tbCurrent := r.Get("globalData").Get("currentTime").Int()
 						if tbCurrent > 0 {
-							tsk.DiffTime = utils.UnixMilli() - tbCurrent
+							diff := utils.UnixMilli() - tbCurrent
+							tsk.mu.Lock()
+							tsk.DiffTime = diff
+							tsk.mu.Unlock()
 							tsk.IsSyncTime = true
 							tbTime := time.Unix(tbCurrent/1e3, 0)
-							logger.Info("淘宝时间戳：", tbCurrent, tbTime.Format(utils.DateTimeFormatStr))
-							logger.Info("服务器与本地时间差为: ", tsk.DiffTime, "ms")
+							tsk.log.Info("淘宝时间戳：", tbCurrent, tbTime.Format(utils.DateTimeFormatStr))
+							tsk.log.Info("服务器与本地时间差为: ", diff, "ms")
+							tsk.Events.Emit(Event{Type: EventTimeSynced, ClockOffset: &diff})
 						}
 					}
 				}()
@@ -113,24 +197,33 @@ func (tsk *tmSecKill) InitActionFunc() chromedp.ActionFunc {
 	}
 }
 
-func (tsk *tmSecKill) Run() error {
+func (tsk *tmSecKill) Run() (err error) {
+	defer func() {
+		err = classifyStop(err)
+		tsk.Events.Emit(Event{Type: EventStopped, ErrorClass: ErrorClass(err), Error: errString(err)})
+	}()
 	return chromedp.Run(tsk.ctx.Ctx, chromedp.Tasks{
 		tsk.InitActionFunc(),
 		chromedp.Navigate("https://login.taobao.com/member/login.jhtml"),
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			logger.Info("等待登陆......")
+			tsk.log.Info("等待登陆......")
+			tsk.Events.Emit(Event{Type: EventLoginWaiting})
 			for {
 				select {
 				case <-tsk.ctx.Ctx.Done():
-					logger.Error("浏览器被关闭，退出进程")
-					return nil
+					tsk.log.Error("浏览器被关闭，退出进程")
+					return ErrLoginFailed
 				case <-tsk.bCtx.Done():
-					logger.Error("浏览器被关闭，退出进程")
-					return nil
+					tsk.log.Error("浏览器被关闭，退出进程")
+					return ErrLoginFailed
 				default:
 				}
 				if tsk.isLogin {
-					logger.Info("登陆成功........")
+					if err := chrome.RegisterSessionCookies(ctx); err != nil {
+						tsk.log.Warn("会话cookie注册失败：", err)
+					}
+					tsk.log.Info("登陆成功........")
+					tsk.Events.Emit(Event{Type: EventLoginOk})
 					break
 				}
 			}
@@ -139,53 +232,68 @@ func (tsk *tmSecKill) Run() error {
 		}),
 		tsk.WaitStart(),
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			for _, c := range tsk.bWorksCtx {
+			for i, c := range tsk.bWorksCtx {
 				go func(ctx2 context.Context) {
+					log := logger.FromContext(ctx2)
 					for {
-						logger.Info("开始提交订单............")
+						log.Info("开始提交订单............")
 						select {
 						case <-tsk.ctx.Ctx.Done():
-							logger.Error("浏览器被关闭，退出进程")
+							log.Error("浏览器被关闭，退出进程")
 							return
 						case <-tsk.bCtx.Done():
-							logger.Error("浏览器被关闭，退出进程")
+							log.Error("浏览器被关闭，退出进程")
 							return
 						default:
 						}
+						tsk.markFire()
+						begin := time.Now()
 						if err := tsk.SubmitOrder(ctx2); err != nil {
+							tsk.Events.Emit(attemptEvent(ctx2, StageSubmit, begin, AttemptFail))
 							tsk.SelectSkuCat(ctx2)
-							logger.Error("订单提交错误，等待重试")
+							log.Error("订单提交错误，等待重试")
+							time.Sleep(retryDelay(tsk.Strategy))
 							continue
 						}
+						tsk.Events.Emit(attemptEvent(ctx2, StageSubmit, begin, AttemptOk))
 						break
 					}
-				}(c)
+				}(withWorker(c, i+1))
+			}
+			var timeout <-chan time.Time
+			if tsk.Timeout > 0 {
+				timeout = time.After(time.Until(tsk.StartTime) + tsk.Timeout)
 			}
 			select {
 			case <-tsk.IsOkChan:
-				logger.Info("抢购成功。。。10s后关闭进程...")
+				tsk.log.Info("抢购成功。。。10s后关闭进程...")
 				_ = chromedp.Sleep(10 * time.Second).Do(ctx)
+			case <-timeout:
+				return ErrTimeout
 			case <-tsk.ctx.Ctx.Done():
+				return stopped(tsk.ctx.Ctx)
 			case <-tsk.bCtx.Done():
+				return stopped(tsk.bCtx)
 			}
 			return nil
 		}),
 	})
 }
 
+// WaitStart 打开抢购标签后等待到开始时间或收到Fire再开始提交订单
 func (tsk *tmSecKill) WaitStart() chromedp.ActionFunc {
 	return func(ctx context.Context) error {
 		st := tsk.StartTime.UnixNano() / 1e6
 
-		logger.Info("等待时间同步，如没有自动同步时间，可手动在购物车页面取消/选中对应的sku商品，期间请勿关闭浏览器")
+		tsk.log.Info("等待时间同步，如没有自动同步时间，可手动在购物车页面取消/选中对应的sku商品，期间请勿关闭浏览器")
 		for {
 			select {
 			case <-tsk.ctx.Ctx.Done():
-				logger.Error("浏览器被关闭，退出进程")
-				return nil
+				tsk.log.Error("浏览器被关闭，退出进程")
+				return stopped(tsk.ctx.Ctx)
 			case <-tsk.bCtx.Done():
-				logger.Error("浏览器被关闭，退出进程")
-				return nil
+				tsk.log.Error("浏览器被关闭，退出进程")
+				return stopped(tsk.bCtx)
 			default:
 			}
 			if tsk.IsSyncTime {
@@ -193,8 +301,12 @@ func (tsk *tmSecKill) WaitStart() chromedp.ActionFunc {
 			}
 		}
 
+		tsk.mu.Lock()
+		works := tsk.Works
+		tsk.isOpened = true
+		tsk.mu.Unlock()
 		wg := sync.WaitGroup{}
-		for i := 0; i < tsk.Works; i++ {
+		for i := 0; i < works; i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -203,7 +315,7 @@ func (tsk *tmSecKill) WaitStart() chromedp.ActionFunc {
 					c, _ := chromedp.NewContext(tsk.bCtx, chromedp.WithTargetID(tid))
 					_ = chromedp.Run(c, chromedp.Tasks{
 						chromedp.ActionFunc(func(ctx context.Context) error {
-							logger.Info("打开新的抢购标签.....")
+							tsk.log.Info("打开新的抢购标签.....")
 							tsk.mu.Lock()
 							tsk.bWorksCtx = append(tsk.bWorksCtx, ctx)
 							tsk.mu.Unlock()
@@ -215,30 +327,28 @@ func (tsk *tmSecKill) WaitStart() chromedp.ActionFunc {
 			}()
 		}
 		wg.Wait()
-		logger.Info("等待时间到达" + tsk.StartTime.Format(utils.DateTimeFormatStr) + "...... 请勿关闭浏览器")
-		return nil
-		for {
-			select {
-			case <-tsk.ctx.Ctx.Done():
-				logger.Error("浏览器被关闭，退出进程")
-				return nil
-			case <-tsk.bCtx.Done():
-				logger.Error("浏览器被关闭，退出进程")
-				return nil
-			default:
-			}
-
-			if utils.UnixMilli()-tsk.DiffTime >= st {
-				logger.Info("时间到达。。。。开始执行")
-				break
-			}
+		tsk.log.Info("等待时间到达" + tsk.StartTime.Format(utils.DateTimeFormatStr) + "...... 请勿关闭浏览器")
+		startTime := tsk.StartTime
+		tsk.Events.Emit(Event{Type: EventWaiting, StartTime: &startTime})
+		tsk.mu.Lock()
+		diff := tsk.DiffTime
+		tsk.mu.Unlock()
+		if !waitUntil(tsk.bCtx, st, diff, tsk.fireChan) {
+			tsk.log.Error("浏览器被关闭，退出进程")
+			return stopped(tsk.bCtx)
 		}
+		tsk.mu.Lock()
+		tsk.isFired = true
+		tsk.mu.Unlock()
+		tsk.log.Info("时间到达。。。。开始执行")
+		tsk.Events.Emit(Event{Type: EventFireStarted})
 		return nil
 	}
 }
 
 //选中购物车中对应的商品
 func (tsk *tmSecKill) SelectSkuCat(ctx context.Context) {
+	log := logger.FromContext(ctx)
 	_, _, _, _ = page.Navigate("https://cart.taobao.com/cart.htm").WithReferrer("https://www.taobao.com/").Do(ctx)
 	var jNodes []*cdp.Node
 	_ = chromedp.Nodes("#J_OrderList div", &jNodes).Do(ctx)
@@ -253,10 +363,10 @@ func (tsk *tmSecKill) SelectSkuCat(ctx context.Context) {
 				if n2.AttributeValue("type") == "checkbox" {
 					err := chromedp.Click(`document.querySelector("[for='`+n2.AttributeValue("id")+`']")`, chromedp.ByJSPath).Do(ctx)
 					if err != nil {
-						logger.Error(err)
+						log.Error(err)
 					}
 					step++
-					logger.Info(n2.AttributeValue("checked"), "====", n2.AttributeValue("value"), "========", n2.AttributeValue("id"))
+					log.Info(n2.AttributeValue("checked"), "====", n2.AttributeValue("value"), "========", n2.AttributeValue("id"))
 				}
 
 				//设置数量
@@ -275,21 +385,22 @@ func (tsk *tmSecKill) SelectSkuCat(ctx context.Context) {
 			break
 		}
 	}
-	logger.Info("选中商品完成.......")
+	log.Info("选中商品完成.......")
 }
 
 func (tsk *tmSecKill) SubmitOrder(ctx context.Context) error {
+	log := logger.FromContext(ctx)
 	for {
 		select {
 		case <-tsk.ctx.Ctx.Done():
-			logger.Error("浏览器被关闭，退出进程")
+			log.Error("浏览器被关闭，退出进程")
 			return nil
 		case <-tsk.bCtx.Done():
-			logger.Error("浏览器被关闭，退出进程")
+			log.Error("浏览器被关闭，退出进程")
 			return nil
 		default:
 		}
-		logger.Info("准备结算...........")
+		log.Info("准备结算...........")
 		var JGOValue string
 		for {
 			//方式点击过快 淘宝js还没有移除这个class
@@ -303,7 +414,7 @@ func (tsk *tmSecKill) SubmitOrder(ctx context.Context) error {
 			break
 		}
 	}
-	logger.Info("等待跳转结算页面.....")
+	log.Info("等待跳转结算页面.....")
 	ch, cc := chrome.WaitDocumentUpdated(ctx)
 	defer cc()
 	<-ch
@@ -311,7 +422,7 @@ func (tsk *tmSecKill) SubmitOrder(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	logger.Info("跳转页面URL：", tInfo.URL)
+	log.Info("跳转页面URL：", tInfo.URL)
 	if !strings.Contains(tInfo.URL, "order/confirm_order.htm") {
 		return errors.New("提交订单错误")
 	}
@@ -329,7 +440,7 @@ func (tsk *tmSecKill) SubmitOrder(ctx context.Context) error {
 	if len(subNodes) == 0 {
 		return errors.New("未找到提交支付按钮.............")
 	}
-	logger.Info("准备提交支付...........")
+	log.Info("准备提交支付...........")
 	isOk := false
 	for _, n := range subNodes {
 		if n.AttributeValue("title") == "提交订单" {
@@ -344,6 +455,7 @@ func (tsk *tmSecKill) SubmitOrder(ctx context.Context) error {
 		return errors.New("订单提交失败")
 	}
 	tsk.IsOk = true
+	tsk.Events.Emit(Event{Type: EventOrderSucceeded, Worker: workerFrom(ctx)})
 	tsk.IsOkChan <- struct{}{}
 	return nil
 }
@@ -116,13 +116,31 @@ func AddDefaultOptions(option ...chromedp.ExecAllocatorOption) {
 	DefaultOptions = append(DefaultOptions, option...)
 }
 
+// HasBrowser report whether ctx is attached to a browser tab, cookies are only
+// read from and written to the browser when it is
+func HasBrowser(ctx context.Context) bool {
+	c := chromedp.FromContext(ctx)
+	return c != nil && c.Target != nil
+}
+
+type transportKey struct{}
+
+// WithTransport 返回RequestByCookie通过rt发送请求的ctx，用于录制和回放，nil为http.DefaultTransport
+func WithTransport(ctx context.Context, rt http.RoundTripper) context.Context {
+	return context.WithValue(ctx, transportKey{}, rt)
+}
+
 func RequestByCookie(ctx context.Context, req *http.Request, isDisableRedirects bool) (*http.Response, error) {
-	httpClient := &http.Client{}
+	rt, _ := ctx.Value(transportKey{}).(http.RoundTripper)
+	httpClient := &http.Client{Transport: rt}
 	if isDisableRedirects {
 		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
 	}
+	if !HasBrowser(ctx) {
+		return httpClient.Do(req.WithContext(ctx))
+	}
 	cookies, err := network.GetCookies().WithUrls([]string{req.URL.String()}).Do(ctx)
 	if err != nil {
 		return nil, err
@@ -132,12 +150,30 @@ func RequestByCookie(ctx context.Context, req *http.Request, isDisableRedirects
 			Name:       c.Name,
 			Value:      c.Value,
 		})
-		logger.Info("cookie: ", c.Value)
 	}
 	return httpClient.Do(req)
 }
 
 
+// 长度不小于该值的cookie视为登陆会话，时间戳等较短的值不注册
+const sessionCookieLen = 16
+
+// RegisterSessionCookies 登陆后调用一次，将浏览器中的会话cookie注册为敏感值，之后日志中都不会输出
+func RegisterSessionCookies(ctx context.Context) error {
+	cookies, err := network.GetAllCookies().Do(ctx)
+	if err != nil {
+		return err
+	}
+	values := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		if len(c.Value) >= sessionCookieLen {
+			values = append(values, c.Value)
+		}
+	}
+	logger.RegisterSecret(values...)
+	return nil
+}
+
 func CreateOptions(opts ...chromedp.ExecAllocatorOption) []chromedp.ExecAllocatorOption {
 	options := append(chromedp.DefaultExecAllocatorOptions[:], DefaultOptions...)
 	options = append(options, opts...)
@@ -176,19 +212,37 @@ func WaitDocumentUpdated(ctx context.Context) (<-chan struct{}, context.CancelFu
 	return ch, ccNew
 }
 
+// NewExecCtx 启动浏览器，chromedp和页面的日志输出到默认logger
 func NewExecCtx(opts ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
-	topC, _ := context.WithCancel(GetGlobalCtx())
-	c, _ := chromedp.NewExecAllocator(topC, CreateOptions(opts...)...)
-	ctx, cancel := chromedp.NewContext(c)
-	return ctx, cancel
+	return NewExecCtxWithLogger(logger.GetlocalLogger(), opts...)
+}
+
+// NewExecCtxWithLogger 启动浏览器，chromedp和页面的日志输出到l，返回的cancel关闭浏览器
+func NewExecCtxWithLogger(l *logger.LocalLogger, opts ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
+	topC, topCC := context.WithCancel(GetGlobalCtx())
+	c, cc := chromedp.NewExecAllocator(topC, CreateOptions(opts...)...)
+	ctx, cancel := chromedp.NewContext(c, LogOptions(l)...)
+	ListenConsole(ctx, l)
+	return ctx, func() {
+		cancel()
+		cc()
+		topCC()
+	}
 }
 
 func NewExecRemoteCtx(remoteWs string, opts ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
-	topC, _ := context.WithCancel(GetGlobalCtx())
-	c, _ := chromedp.NewExecAllocator(topC, CreateOptions(opts...)...)
-	c, _ = chromedp.NewRemoteAllocator(c, remoteWs)
-	ctx, cancel := chromedp.NewContext(c)
-	return ctx, cancel
+	l := logger.GetlocalLogger()
+	topC, topCC := context.WithCancel(GetGlobalCtx())
+	c, cc := chromedp.NewExecAllocator(topC, CreateOptions(opts...)...)
+	c, rc := chromedp.NewRemoteAllocator(c, remoteWs)
+	ctx, cancel := chromedp.NewContext(c, LogOptions(l)...)
+	ListenConsole(ctx, l)
+	return ctx, func() {
+		cancel()
+		rc()
+		cc()
+		topCC()
+	}
 }
 
 func NewExecAllocator(tasks chromedp.Tasks, opts ...chromedp.ExecAllocatorOption) error {
@@ -197,7 +251,7 @@ func NewExecAllocator(tasks chromedp.Tasks, opts ...chromedp.ExecAllocatorOption
 	defer topCC()
 	c, cc := chromedp.NewExecAllocator(topC, CreateOptions(opts...)...)
 	defer cc()
-	ctx, cancel := chromedp.NewContext(c)
+	ctx, cancel := chromedp.NewContext(c, LogOptions(logger.GetlocalLogger())...)
 	defer cancel()
 	_ = log.Disable().Do(ctx)
 	err := chromedp.Run(ctx, tasks)
@@ -0,0 +1,96 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+// DebugProtocol 为true时以TRAC等级输出所有CDP协议消息，数量很多，仅用于排查问题
+var DebugProtocol bool
+
+// LogOptions 返回将chromedp的日志输出到l的选项：一般日志为DEBG，CDP错误为EROR，
+// DebugProtocol为true时协议消息为TRAC。日志来源包为chrome，可通过chrome=WARN等设置等级
+func LogOptions(l *logger.LocalLogger) []chromedp.ContextOption {
+	l = l.With("source", "cdp")
+	opts := []chromedp.ContextOption{
+		chromedp.WithLogf(func(format string, v ...interface{}) {
+			l.Debug(format, v...)
+		}),
+		chromedp.WithErrorf(func(format string, v ...interface{}) {
+			l.Error(format, v...)
+		}),
+	}
+	if DebugProtocol {
+		opts = append(opts, chromedp.WithDebugf(func(format string, v ...interface{}) {
+			l.Trace(format, v...)
+		}))
+	}
+	return opts
+}
+
+// ListenConsole 将页面的console输出和Log.entryAdded日志输出到l。页面日志不是mts的错误，
+// 等级相应降低：error为WARN，warning为INFO，log、info为DEBG，其余为TRAC
+func ListenConsole(ctx context.Context, l *logger.LocalLogger) {
+	l = l.With("source", "console")
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			text := remoteText(e.Args)
+			switch e.Type {
+			case runtime.APITypeError, runtime.APITypeAssert:
+				l.Warn("页面console.%s: %s", e.Type, text)
+			case runtime.APITypeWarning:
+				l.Info("页面console.%s: %s", e.Type, text)
+			case runtime.APITypeLog, runtime.APITypeInfo:
+				l.Debug("页面console.%s: %s", e.Type, text)
+			default:
+				l.Trace("页面console.%s: %s", e.Type, text)
+			}
+		case *log.EventEntryAdded:
+			entry := e.Entry
+			el := l
+			if entry.URL != "" {
+				el = l.With("url", entry.URL)
+			}
+			switch entry.Level {
+			case log.LevelError:
+				el.Warn("页面%s日志: %s", entry.Source, entry.Text)
+			case log.LevelWarning:
+				el.Info("页面%s日志: %s", entry.Source, entry.Text)
+			case log.LevelInfo:
+				el.Debug("页面%s日志: %s", entry.Source, entry.Text)
+			default:
+				el.Trace("页面%s日志: %s", entry.Source, entry.Text)
+			}
+		}
+	})
+}
+
+// remoteText 将console的参数转换为文本，字符串不带引号
+func remoteText(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		switch {
+		case len(a.Value) > 0:
+			var s string
+			if json.Unmarshal(a.Value, &s) == nil {
+				parts = append(parts, s)
+			} else {
+				parts = append(parts, string(a.Value))
+			}
+		case a.UnserializableValue != "":
+			parts = append(parts, a.UnserializableValue.String())
+		case a.Description != "":
+			parts = append(parts, a.Description)
+		default:
+			parts = append(parts, a.Type.String())
+		}
+	}
+	return strings.Join(parts, " ")
+}
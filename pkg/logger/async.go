@@ -0,0 +1,336 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 异步队列满时的处理策略
+const (
+	OverflowBlock          = "block"            // 等待队列有空位，不丢日志
+	OverflowDropOldest     = "drop-oldest"      // 丢弃队列中最早的日志
+	OverflowDropLevelBelow = "drop-level-below" // 丢弃低于Level等级的日志，其余等待
+)
+
+// ErrFlushTimeout 在超时时间内未能写完队列中的日志
+var ErrFlushTimeout = errors.New("logger: flush timeout")
+
+// defaultFlushTimeout Flush、Close和退出时等待的默认时间
+const defaultFlushTimeout = 3 * time.Second
+
+// AsyncConfig 异步输出配置，每个适配器有独立的队列和写入goroutine
+type AsyncConfig struct {
+	Queue        int    `json:"queue"`        // 每个适配器的队列长度，默认1024
+	Overflow     string `json:"overflow"`     // 队列满时的策略，默认block
+	Level        string `json:"level"`        // drop-level-below时保留的最低等级，默认WARN
+	Batch        int    `json:"batch"`        // 每次最多批量写入的条数，默认64
+	FlushTimeout string `json:"flushTimeout"` // Flush、Close等待的最长时间，默认3s
+}
+
+// batchWriter is implemented by adapters which can write several messages at
+// once, msg of the records is what LogWrite would receive
+type batchWriter interface {
+	LogWriteBatch(recs []logRecord) error
+}
+
+// logRecord 队列中的一条日志
+type logRecord struct {
+	when  time.Time
+	msg   interface{}
+	level int
+}
+
+type asyncOptions struct {
+	queue        int
+	overflow     string
+	level        int
+	batch        int
+	flushTimeout time.Duration
+}
+
+// validate 检查配置是否有效
+func (c *AsyncConfig) validate() error {
+	_, err := c.options()
+	return err
+}
+
+func (c *AsyncConfig) options() (asyncOptions, error) {
+	o := asyncOptions{queue: 1024, overflow: OverflowBlock, level: LevelWarning, batch: 64, flushTimeout: defaultFlushTimeout}
+	if c.Queue > 0 {
+		o.queue = c.Queue
+	}
+	if c.Batch > 0 {
+		o.batch = c.Batch
+	}
+	switch c.Overflow {
+	case "":
+	case OverflowBlock, OverflowDropOldest, OverflowDropLevelBelow:
+		o.overflow = c.Overflow
+	default:
+		return o, fmt.Errorf("logger: unknown overflow policy %s", c.Overflow)
+	}
+	if c.Level != "" {
+		l, ok := LevelMap[strings.ToUpper(c.Level)]
+		if !ok {
+			return o, fmt.Errorf("logger: unknown level %s", c.Level)
+		}
+		o.level = l
+	}
+	if c.FlushTimeout != "" {
+		d, err := time.ParseDuration(c.FlushTimeout)
+		if err != nil {
+			return o, err
+		}
+		o.flushTimeout = d
+	}
+	return o, nil
+}
+
+// asyncQueue 一个适配器的异步队列
+type asyncQueue struct {
+	opts    asyncOptions
+	out     *nameLogger
+	format  func(l *nameLogger, r logRecord) interface{}
+	ch      chan logRecord
+	pending int64
+	dropped *uint64
+	done    chan struct{}
+	// mu 放入时持有读锁，关闭时持有写锁，放入不需要持有loggerCore的lock
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncQueue(out *nameLogger, opts asyncOptions, dropped *uint64, format func(*nameLogger, logRecord) interface{}) *asyncQueue {
+	q := &asyncQueue{
+		opts:    opts,
+		out:     out,
+		format:  format,
+		ch:      make(chan logRecord, opts.queue),
+		dropped: dropped,
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// push 按溢出策略放入队列，队列已关闭时返回false
+func (q *asyncQueue) push(r logRecord) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	atomic.AddInt64(&q.pending, 1)
+	select {
+	case q.ch <- r:
+		return true
+	default:
+	}
+	switch q.opts.overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.ch <- r:
+				return true
+			default:
+			}
+			select {
+			case <-q.ch:
+				q.drop()
+			default:
+			}
+		}
+	case OverflowDropLevelBelow:
+		if r.level > q.opts.level {
+			q.drop()
+			return true
+		}
+	}
+	q.ch <- r
+	return true
+}
+
+func (q *asyncQueue) drop() {
+	atomic.AddInt64(&q.pending, -1)
+	atomic.AddUint64(q.dropped, 1)
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	batch := make([]logRecord, 0, q.opts.batch)
+	for r := range q.ch {
+		batch = append(batch[:0], r)
+	FILL:
+		for len(batch) < q.opts.batch {
+			select {
+			case r, ok := <-q.ch:
+				if !ok {
+					break FILL
+				}
+				batch = append(batch, r)
+			default:
+				break FILL
+			}
+		}
+		q.writeBatch(batch)
+		atomic.AddInt64(&q.pending, -int64(len(batch)))
+	}
+}
+
+func (q *asyncQueue) writeBatch(batch []logRecord) {
+	for i := range batch {
+		batch[i].msg = q.format(q.out, batch[i])
+	}
+	if bw, ok := q.out.Logger.(batchWriter); ok && len(batch) > 1 {
+		if err := bw.LogWriteBatch(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", q.out.name, err)
+		}
+		return
+	}
+	for _, r := range batch {
+		if err := q.out.LogWrite(r.when, r.msg, r.level); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", q.out.name, err)
+		}
+	}
+}
+
+// flush 等待队列写完，超时返回ErrFlushTimeout
+func (q *asyncQueue) flush(deadline time.Time) error {
+	for atomic.LoadInt64(&q.pending) > 0 {
+		if time.Now().After(deadline) {
+			return ErrFlushTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// close 写完队列后停止goroutine，超时后放弃剩余日志
+func (q *asyncQueue) close(deadline time.Time) error {
+	err := q.flush(deadline)
+	// 等待正在放入的日志，写入goroutine仍在运行，阻塞的push会完成
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+	select {
+	case <-q.done:
+	case <-time.After(time.Until(deadline)):
+		if err == nil {
+			err = ErrFlushTimeout
+		}
+	}
+	return err
+}
+
+// SetAsync 开启异步输出，nil时写完队列后恢复同步输出
+func (this *LocalLogger) SetAsync(c *AsyncConfig) error {
+	var opts asyncOptions
+	if c != nil {
+		var err error
+		if opts, err = c.options(); err != nil {
+			return err
+		}
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var err error
+	for _, l := range this.outputs {
+		if e := this.closeQueue(l); e != nil {
+			err = e
+		}
+	}
+	this.async = nil
+	if c == nil {
+		return err
+	}
+	this.async = &opts
+	for _, l := range this.outputs {
+		l.queue = newAsyncQueue(l, opts, &this.dropped, this.format)
+	}
+	return err
+}
+
+// closeQueue 写完l的队列并恢复同步输出，调用时需持有lock
+func (c *loggerCore) closeQueue(l *nameLogger) error {
+	if l.queue == nil {
+		return nil
+	}
+	err := l.queue.close(time.Now().Add(l.queue.opts.flushTimeout))
+	l.queue = nil
+	return err
+}
+
+// Flush 等待异步队列中的日志写完，再调用各适配器的Flush，超时返回ErrFlushTimeout
+func (this *LocalLogger) Flush() error {
+	this.flushSampler()
+	this.lock.Lock()
+	outputs := append([]*nameLogger(nil), this.outputs...)
+	var queues []*asyncQueue
+	for _, l := range outputs {
+		if l.queue != nil {
+			queues = append(queues, l.queue)
+		}
+	}
+	timeout := defaultFlushTimeout
+	if this.async != nil {
+		timeout = this.async.flushTimeout
+	}
+	this.lock.Unlock()
+	deadline := time.Now().Add(timeout)
+	var err error
+	for _, q := range queues {
+		if e := q.flush(deadline); e != nil {
+			err = e
+		}
+	}
+	if e := flushOutputs(outputs, deadline); e != nil {
+		err = e
+	}
+	return err
+}
+
+// flushOutputs 并发调用各适配器的Flush，deadline前未返回时返回ErrFlushTimeout
+func flushOutputs(outputs []*nameLogger, deadline time.Time) error {
+	errs := make(chan error, len(outputs))
+	for _, l := range outputs {
+		go func(l *nameLogger) {
+			err := l.Flush()
+			if err != nil {
+				err = fmt.Errorf("flush %s: %v", l.name, err)
+			}
+			errs <- err
+		}(l)
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	var err error
+	for range outputs {
+		select {
+		case e := <-errs:
+			if e != nil {
+				err = e
+			}
+		case <-timer.C:
+			return ErrFlushTimeout
+		}
+	}
+	return err
+}
+
+// Dropped 返回异步队列满时丢弃的日志条数
+func (this *LocalLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&this.dropped)
+}
+
+// Flush waits for the async queues of the default logger
+func Flush() error {
+	return defaultLogger.Flush()
+}
@@ -0,0 +1,288 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateLogger 记录收到的日志，gate非nil时每次写入前等待，用于模拟慢速输出
+type gateLogger struct {
+	sync.Mutex
+	gate  chan struct{}
+	delay time.Duration
+	msgs  []string
+}
+
+func (g *gateLogger) Init(string) error {
+	g.Lock()
+	g.msgs = nil
+	g.Unlock()
+	return nil
+}
+
+func (g *gateLogger) LogWrite(when time.Time, msg interface{}, level int) error {
+	g.Lock()
+	ch, delay := g.gate, g.delay
+	g.Unlock()
+	if ch != nil {
+		<-ch
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	g.Lock()
+	g.msgs = append(g.msgs, msg.(string))
+	g.Unlock()
+	return nil
+}
+
+func (g *gateLogger) Flush() error { return nil }
+
+func (g *gateLogger) Destroy() {}
+
+func (g *gateLogger) set(ch chan struct{}, delay time.Duration) {
+	g.Lock()
+	g.gate, g.delay = ch, delay
+	g.Unlock()
+}
+
+func (g *gateLogger) received() []string {
+	g.Lock()
+	defer g.Unlock()
+	return append([]string(nil), g.msgs...)
+}
+
+var gate = &gateLogger{}
+
+func init() {
+	Register("asynctest", func() Logger { return gate })
+}
+
+func newGateLogger(t testing.TB, c *AsyncConfig) *LocalLogger {
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	if err := log.SetAsync(c); err != nil {
+		t.Fatal(err)
+	}
+	log.SetLogger("asynctest")
+	return log
+}
+
+const asyncFileConfig = `{"filename":"%s","level":"DEBG","append":false,"maxlines":0,"maxsize":0,"daily":false,"permit":"0660"}`
+
+func TestAsyncFile(t *testing.T) {
+	defer os.Remove("async.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetAsync(&AsyncConfig{Batch: 16})
+	log.SetLogger(AdapterFile, fmt.Sprintf(asyncFileConfig, "async.log"))
+	for i := 0; i < 1000; i++ {
+		log.Info("line", i)
+	}
+	if err := log.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile("async.log")
+	if n := strings.Count(string(b), "\n"); n != 1000 {
+		t.Fatalf("flush wrote %d lines", n)
+	}
+	log.Info("last")
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadFile("async.log")
+	if !strings.HasSuffix(string(b), "last\n") {
+		t.Fatal("close did not drain the queue")
+	}
+}
+
+func TestAsyncDropOldest(t *testing.T) {
+	ch := make(chan struct{})
+	gate.set(ch, 0)
+	defer gate.set(nil, 0)
+	log := newGateLogger(t, &AsyncConfig{Queue: 4, Batch: 1, Overflow: OverflowDropOldest})
+	for i := 0; i < 20; i++ {
+		log.Info("msg", i)
+	}
+	close(ch)
+	if err := log.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := gate.received()
+	if log.Dropped() == 0 || uint64(len(msgs))+log.Dropped() != 20 {
+		t.Fatalf("received %d dropped %d", len(msgs), log.Dropped())
+	}
+	if !strings.HasSuffix(msgs[len(msgs)-1], "msg 19") {
+		t.Fatalf("newest message dropped: %v", msgs)
+	}
+	log.Close()
+}
+
+func TestAsyncDropLevelBelow(t *testing.T) {
+	ch := make(chan struct{})
+	gate.set(ch, 0)
+	defer gate.set(nil, 0)
+	log := newGateLogger(t, &AsyncConfig{Queue: 2, Batch: 1, Overflow: OverflowDropLevelBelow, Level: "WARN"})
+	for i := 0; i < 10; i++ {
+		log.Debug("debug", i)
+	}
+	done := make(chan struct{})
+	go func() {
+		// 队列已满，WARN及以上等待写入
+		for i := 0; i < 5; i++ {
+			log.Error("error", i)
+		}
+		close(done)
+	}()
+	close(ch)
+	<-done
+	log.Flush()
+	errs := 0
+	for _, m := range gate.received() {
+		if strings.Contains(m, "[EROR]") {
+			errs++
+		}
+	}
+	if errs != 5 || log.Dropped() == 0 {
+		t.Fatalf("errors %d dropped %d", errs, log.Dropped())
+	}
+	log.Close()
+}
+
+func TestAsyncFlushTimeout(t *testing.T) {
+	ch := make(chan struct{})
+	gate.set(ch, 0)
+	defer gate.set(nil, 0)
+	log := newGateLogger(t, &AsyncConfig{FlushTimeout: "20ms"})
+	log.Info("blocked")
+	if err := log.Flush(); err != ErrFlushTimeout {
+		t.Fatalf("flush: %v", err)
+	}
+	close(ch)
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&AsyncConfig{Overflow: "never"}).validate(); err == nil {
+		t.Fatal("unknown overflow accepted")
+	}
+}
+
+func TestAsyncBlockOutsideLock(t *testing.T) {
+	ch := make(chan struct{})
+	gate.set(ch, 0)
+	defer gate.set(nil, 0)
+	log := newGateLogger(t, &AsyncConfig{Queue: 1, Batch: 1, FlushTimeout: "20ms"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// 第一条被写入goroutine取出，第二条在队列中，第三条等待空位
+		for i := 0; i < 3; i++ {
+			log.Info("blocked", i)
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// 队列满时不影响修改配置和其他输出
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		log.SetLevel("INFO")
+		log.SetOutput("mem", AdapterMemory, "")
+		_ = log.Flush()
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("full queue blocked SetOutput and Flush")
+	}
+	close(ch)
+	<-done
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := gate.received(); len(msgs) != 3 {
+		t.Fatalf("got %q", msgs)
+	}
+}
+
+// TestAsyncSwitch 输出日志时开关异步，go test -race检查队列的并发访问
+func TestAsyncSwitch(t *testing.T) {
+	log := newGateLogger(t, &AsyncConfig{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				log.Info("switch", i)
+				if i%50 == 0 {
+					_ = log.Flush()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			log.SetAsync(nil)
+		} else {
+			log.SetAsync(&AsyncConfig{})
+		}
+	}
+	wg.Wait()
+	log.Close()
+	if msgs := gate.received(); len(msgs) != 800 {
+		t.Fatalf("got %d messages", len(msgs))
+	}
+}
+
+func benchmarkLogger(b *testing.B, c *AsyncConfig) {
+	gate.set(nil, 20*time.Microsecond)
+	defer gate.set(nil, 0)
+	log := newGateLogger(b, c)
+	log.With("worker", 1, "skuId", "100012043978").Info("warm up")
+	child := log.With("worker", 1, "skuId", "100012043978")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		child.Info("提交订单", i)
+	}
+	b.StopTimer()
+	log.Close()
+}
+
+func BenchmarkSync(b *testing.B) {
+	benchmarkLogger(b, nil)
+}
+
+func BenchmarkAsyncBlock(b *testing.B) {
+	benchmarkLogger(b, &AsyncConfig{Queue: 1 << 16})
+}
+
+func BenchmarkAsyncDropOldest(b *testing.B) {
+	benchmarkLogger(b, &AsyncConfig{Queue: 1024, Overflow: OverflowDropOldest})
+}
+
+func benchmarkFile(b *testing.B, c *AsyncConfig) {
+	defer os.Remove("bench.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetAsync(c)
+	log.SetLogger(AdapterFile, fmt.Sprintf(asyncFileConfig, "bench.log"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log.Info("提交订单", i)
+	}
+	log.Close()
+}
+
+func BenchmarkFileSync(b *testing.B) {
+	benchmarkFile(b, nil)
+}
+
+func BenchmarkFileAsync(b *testing.B) {
+	benchmarkFile(b, &AsyncConfig{Queue: 4096})
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// bridgeWriter 将其他库写入的文本按行输出为日志
+type bridgeWriter struct {
+	log    *LocalLogger
+	level  int
+	module string
+}
+
+func (w *bridgeWriter) Write(p []byte) (int, error) {
+	if !w.log.enabledFor(w.level, w.module) {
+		return len(p), nil
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\r\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			w.log.bridge(w.level, line)
+		}
+	}
+	return len(p), nil
+}
+
+// bridge 输出其他库的日志，不记录调用位置，也不去重和限流
+func (this *LocalLogger) bridge(level int, msg string) {
+	if !this.init {
+		this.SetLogger(AdapterConsole)
+	}
+	this.output(level, time.Now(), 0, msg)
+}
+
+// Writer 返回按行输出level日志的io.Writer，module用于SetLevel中按名称设置等级，如 "log=WARN"
+func (this *LocalLogger) Writer(level int, module string) io.Writer {
+	return &bridgeWriter{log: this, level: level, module: module}
+}
+
+// StdLogger 返回输出到logger的*log.Logger，用于只接受标准库logger的库
+func (this *LocalLogger) StdLogger(level int, module string) *log.Logger {
+	return log.New(this.Writer(level, module), "", 0)
+}
+
+// Logf 返回printf风格的日志函数，module的作用同Writer
+func (this *LocalLogger) Logf(level int, module string) func(format string, v ...interface{}) {
+	return func(format string, v ...interface{}) {
+		if this.enabledFor(level, module) {
+			this.bridge(level, fmt.Sprintf(format, v...))
+		}
+	}
+}
+
+// RedirectStdLog 将标准库log包的输出转到默认logger，等级为level，module为"log"
+func RedirectStdLog(level int) {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(defaultLogger.Writer(level, "log"))
+}
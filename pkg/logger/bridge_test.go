@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"testing"
+)
+
+func TestWriterBridge(t *testing.T) {
+	l, mem := Capture()
+	defer l.Close()
+	l.SetLevel("DEBG,log=INFO")
+	std := l.StdLogger(LevelInformational, "log")
+	std.Printf("dial tcp: i/o timeout")
+	fmt.Fprint(l.Writer(LevelWarning, "log"), "first\r\n\nsecond\n")
+	// log=INFO时不输出DEBG
+	fmt.Fprintln(l.Writer(LevelDebug, "log"), "suppressed")
+	fmt.Fprintln(l.Writer(LevelDebug, "other"), "other module")
+
+	rs := mem.Records(0)
+	if fmt.Sprint(mem.Messages()) != "[dial tcp: i/o timeout first second other module]" {
+		t.Fatalf("unexpected messages %q", mem.Messages())
+	}
+	if rs[0].Level != "INFO" || rs[1].Level != "WARN" || rs[3].Level != "DEBG" || rs[0].Path != "" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+}
+
+func TestLogfBridge(t *testing.T) {
+	l, mem := Capture()
+	defer l.Close()
+	l.SetLevel("INFO,cdp=EROR")
+	errorf := l.With("source", "cdp").Logf(LevelError, "cdp")
+	errorf("could not unmarshal event: %v", "EOF")
+	l.Logf(LevelWarning, "cdp")("dropped %d", 1)
+	rs := mem.Records(0)
+	if len(rs) != 1 || rs[0].Msg != "could not unmarshal event: EOF" || rs[0].Level != "EROR" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+	if s, _ := rs[0].Field("source"); s != "cdp" {
+		t.Fatalf("source %v", s)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	out, flags := log.Writer(), log.Flags()
+	defer func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}()
+	l, mem := Capture()
+	defer l.Close()
+	old := defaultLogger
+	defaultLogger = l
+	defer func() { defaultLogger = old }()
+	RedirectStdLog(LevelInformational)
+	log.Printf("websocket url timeout reached")
+	if rs := mem.Records(0); len(rs) != 1 || rs[0].Msg != "websocket url timeout reached" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+}
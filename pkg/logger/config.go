@@ -4,23 +4,27 @@ import (
 	"encoding/json"
 )
 
-//二次开发logger
-func Cfg(level int, logFIle string) {
+//二次开发logger，format为text或json，默认text
+//level为默认日志等级，适配器不再单独过滤，之后可通过SetLevel调整
+func Cfg(level int, logFIle string, format ...string) {
+	f := append(format, FormatText)[0]
 	var config logConfig
 	if logFIle == "" {
 		config = logConfig{
-			TimeFormat: "15:04:05",
+			TimeFormat: "15:04:05.000",
 			Console: &consoleLogger{
-				LogLevel: level,
+				LogLevel: LevelTrace,
 				Colorful: true,
+				Format:   f,
 			},
 		}
 	} else {
 		config = logConfig{
-			TimeFormat: "15:04:05",
+			TimeFormat: "15:04:05.000",
 			Console: &consoleLogger{
-				LogLevel: level,
+				LogLevel: LevelTrace,
 				Colorful: true,
+				Format:   f,
 			},
 			File: &fileLogger{                  
 				Filename: logFIle,  
@@ -31,12 +35,14 @@ func Cfg(level int, logFIle string) {
 				MaxDays: -1,         
 				Append: true,        
 				PermitMask: "0660",       
+				Format: f,
 			},
 		}
 	}
 	cfg, _ := json.Marshal(config)
 	SetLogger(string(cfg))
 	SetLogPath(true)
+	defaultLogger.levels.Store(&levels{level: level})
 }
 
 // "File": {                // 文件日志配置
@@ -44,8 +50,13 @@ func Cfg(level int, logFIle string) {
 // 	"level": "TRAC",        // 日志文件日志输出等级
 // 	"daily": true,          // 跨天后是否创建新日志文件，当append=true时有效
 // 	"maxlines": 1000000,    // 日志文件最大行数，当append=true时有效
-// 	"maxsize": 1,           // 日志文件最大大小，当append=true时有效
+// 	"maxsize": 1,           // 日志文件最大大小，单位MB，当append=true时有效
 // 	"maxdays": -1,          // 日志文件有效期
 // 	"append": true,         // 是否支持日志追加
-// 	"permit": "0660"        // 新创建的日志文件权限属性
+// 	"permit": "0660",       // 新创建的日志文件权限属性
+// 	"hourly": false,        // 每小时创建新日志文件，当append=true时有效
+// 	"maxfiles": 30,         // 最多保留的切分文件数
+// 	"maxtotalsize": 512,    // 切分文件最多占用的空间，单位MB
+// 	"compress": true,       // 后台将切分后的文件压缩为.gz
+// 	"symlink": false        // 写入带日期的文件，filename为指向当前文件的软链接
 // },
\ No newline at end of file
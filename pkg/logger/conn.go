@@ -1,33 +1,85 @@
 package logger
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// 网络日志默认配置
+const (
+	connDefaultSpool      = 1024
+	connDefaultMinBackoff = 500 * time.Millisecond
+	connDefaultMaxBackoff = 30 * time.Second
+	connDefaultTimeout    = 5 * time.Second
+)
+
+// connLogger 网络日志，LogWrite只将消息放入队列，由后台goroutine发送，
+// 网络断开时按指数退避重连，期间的消息暂存在内存中，超出后写入磁盘或丢弃最早的消息
 type connLogger struct {
-	sync.Mutex
+	sync.Mutex     // 保护队列和统计
 	innerWriter    io.WriteCloser
 	ReconnectOnMsg bool   `json:"reconnectOnMsg"`
-	Reconnect      bool   `json:"reconnect"`
+	Reconnect      bool   `json:"reconnect"` // 已废弃，断开后总是在后台重连
 	Net            string `json:"net"`
 	Addr           string `json:"addr"`
 	Level          string `json:"level"`
 	LogLevel       int
-	illNetFlag     bool //网络异常标记
+
+	TLS                bool   `json:"tls,omitempty"`                // 使用tls连接，net为tcp时有效
+	CAFile             string `json:"caFile,omitempty"`             // 校验服务端证书的CA，默认使用系统CA
+	CertFile           string `json:"certFile,omitempty"`           // 客户端证书
+	KeyFile            string `json:"keyFile,omitempty"`            // 客户端证书私钥
+	ServerName         string `json:"serverName,omitempty"`         // 校验的服务端名称，默认为addr中的主机名
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // 不校验服务端证书，仅用于测试
+	MinBackoff         string `json:"minBackoff,omitempty"`         // 重连的初始等待时间，默认500ms
+	MaxBackoff         string `json:"maxBackoff,omitempty"`         // 重连的最长等待时间，默认30s
+	Spool              int    `json:"spool,omitempty"`              // 未发送消息在内存中最多保存的条数，默认1024
+	SpillFile          string `json:"spillFile,omitempty"`          // 内存队列满后写入该文件，重连后发送
+	SpillMaxSize       int    `json:"spillMaxSize,omitempty"`       // spillFile最大大小，单位MB，默认64
+
+	tlsConfig  *tls.Config
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	queue    [][]byte
+	spill    *os.File
+	spillOff int64 // 下一条待发送消息在spillFile中的位置
+	spillEnd int64
+	notify   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+
+	sent      uint64
+	dropped   uint64
+	connected int32
+}
+
+// ConnStats 网络日志的发送统计
+type ConnStats struct {
+	Sent      uint64 // 已发送的消息数
+	Dropped   uint64 // 队列满丢弃的消息数
+	Queued    int    // 内存中等待发送的消息数
+	Spilled   int64  // spillFile中等待发送的字节数
+	Connected bool
 }
 
 func (c *connLogger) Init(jsonConfig string) error {
 	if len(jsonConfig) == 0 {
 		return nil
 	}
-	fmt.Printf("consoleWriter Init:%s\n", jsonConfig)
+	c.shutdown()
 	err := json.Unmarshal([]byte(jsonConfig), c)
 	if err != nil {
 		return err
@@ -35,9 +87,62 @@ func (c *connLogger) Init(jsonConfig string) error {
 	if l, ok := LevelMap[c.Level]; ok {
 		c.LogLevel = l
 	}
-	if c.innerWriter != nil {
-		c.innerWriter.Close()
-		c.innerWriter = nil
+	if err = c.parseConfig(); err != nil {
+		return err
+	}
+	if c.SpillFile != "" {
+		if err = c.openSpill(); err != nil {
+			return err
+		}
+	}
+	c.notify = make(chan struct{}, 1)
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run(c.stop, c.done)
+	c.wake()
+	return nil
+}
+
+func (c *connLogger) parseConfig() (err error) {
+	c.minBackoff, c.maxBackoff = connDefaultMinBackoff, connDefaultMaxBackoff
+	if c.MinBackoff != "" {
+		if c.minBackoff, err = time.ParseDuration(c.MinBackoff); err != nil {
+			return err
+		}
+	}
+	if c.MaxBackoff != "" {
+		if c.maxBackoff, err = time.ParseDuration(c.MaxBackoff); err != nil {
+			return err
+		}
+	}
+	if c.Spool <= 0 {
+		c.Spool = connDefaultSpool
+	}
+	if c.SpillMaxSize <= 0 {
+		c.SpillMaxSize = 64
+	}
+	c.tlsConfig = nil
+	if !c.TLS {
+		return nil
+	}
+	c.tlsConfig = &tls.Config{ServerName: c.ServerName, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+		c.tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return err
+		}
+		c.tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 	return nil
 }
@@ -51,91 +156,324 @@ func (c *connLogger) LogWrite(when time.Time, msgText interface{}, level int) (e
 	if !ok {
 		return
 	}
+	ss, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.enqueue(append(ss, '\n'))
+	return nil
+}
 
-	if c.needToConnectOnMsg() {
-		err = c.connect()
-		if err != nil {
+// enqueue 放入发送队列，spillFile中有消息时新消息也写入spillFile，保证发送顺序
+func (c *connLogger) enqueue(line []byte) {
+	c.Lock()
+	switch {
+	case c.spillEnd == 0 && len(c.queue) < c.Spool:
+		c.queue = append(c.queue, line)
+	case c.spill != nil:
+		if c.spillEnd+int64(len(line)) > int64(c.SpillMaxSize)*1024*1024 {
+			c.dropped++
+			break
+		}
+		if _, err := c.spill.WriteAt(line, c.spillEnd); err != nil {
+			fmt.Fprintf(os.Stderr, "conn logger spill error:%v\n", err)
+			c.dropped++
+			break
+		}
+		c.spillEnd += int64(len(line))
+	case len(c.queue) > 0:
+		// 丢弃最早的消息
+		c.queue = append(c.queue[1:], line)
+		c.dropped++
+	default:
+		c.dropped++
+	}
+	c.Unlock()
+	c.wake()
+}
+
+func (c *connLogger) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run 发送队列中的消息，连接失败时按指数退避重连
+func (c *connLogger) run(stop, done chan struct{}) {
+	defer close(done)
+	backoff := c.minBackoff
+	for {
+		select {
+		case <-stop:
 			return
+		case <-c.notify:
+		}
+		for c.pending() {
+			if c.innerWriter == nil {
+				if err := c.connect(); err != nil {
+					fmt.Fprintf(os.Stderr, "conn logger connect error:%v, retry after %v\n", err, backoff)
+					select {
+					case <-stop:
+						return
+					case <-time.After(backoff):
+					}
+					if backoff *= 2; backoff > c.maxBackoff {
+						backoff = c.maxBackoff
+					}
+					continue
+				}
+				backoff = c.minBackoff
+			}
+			err := c.flush()
+			//每条消息都重连一次日志中心，适用于写日志频率极低的情况下的服务调用,避免长时间连接，占用资源
+			if err != nil || c.ReconnectOnMsg { // 频繁日志发送切勿开启
+				c.closeConn()
+			}
 		}
-		//重连成功
-		c.illNetFlag = false
 	}
+}
+
+func (c *connLogger) pending() bool {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.queue) > 0 || c.spillOff < c.spillEnd
+}
 
-	//每条消息都重连一次日志中心，适用于写日志频率极低的情况下的服务调用,避免长时间连接，占用资源
-	if c.ReconnectOnMsg { // 频繁日志发送切勿开启
-		defer c.innerWriter.Close()
+// flush 先发送内存中的消息，再发送spillFile中的消息
+func (c *connLogger) flush() error {
+	for {
+		c.Lock()
+		batch := c.queue
+		c.queue = nil
+		c.Unlock()
+		if len(batch) == 0 {
+			return c.flushSpill()
+		}
+		for i, line := range batch {
+			if _, err := c.innerWriter.Write(line); err != nil {
+				c.requeue(batch[i:])
+				return err
+			}
+			atomic.AddUint64(&c.sent, 1)
+		}
 	}
+}
 
-	//网络异常时，消息发出
-	if !c.illNetFlag {
-		err = c.println(when, msg)
-		//网络异常，通知处理网络的go程自动重连
-		if err != nil {
-			c.illNetFlag = true
+// requeue 将未发送的消息放回队列头部，超出Spool的部分丢弃最早的消息
+func (c *connLogger) requeue(lines [][]byte) {
+	c.Lock()
+	defer c.Unlock()
+	c.queue = append(lines[:len(lines):len(lines)], c.queue...)
+	if n := len(c.queue) - c.Spool; n > 0 {
+		c.queue = c.queue[n:]
+		c.dropped += uint64(n)
+	}
+}
+
+// flushSpill 按行发送spillFile中的消息，全部发送后清空文件
+func (c *connLogger) flushSpill() error {
+	buf := make([]byte, 64*1024)
+	for {
+		c.Lock()
+		if c.spillOff >= c.spillEnd {
+			if c.spill != nil && c.spillEnd > 0 {
+				c.spill.Truncate(0)
+				c.spillOff, c.spillEnd = 0, 0
+			}
+			c.Unlock()
+			return nil
+		}
+		n, err := c.spill.ReadAt(buf[:min64(int64(len(buf)), c.spillEnd-c.spillOff)], c.spillOff)
+		c.Unlock()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		chunk := buf[:n]
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			chunk = chunk[:i+1]
+		} else if len(chunk) == len(buf) {
+			// 单条消息超过缓冲区
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		for len(chunk) > 0 {
+			i := bytes.IndexByte(chunk, '\n') + 1
+			if i == 0 {
+				i = len(chunk)
+			}
+			if _, err = c.innerWriter.Write(chunk[:i]); err != nil {
+				return err
+			}
+			atomic.AddUint64(&c.sent, 1)
+			c.Lock()
+			c.spillOff += int64(i)
+			c.Unlock()
+			chunk = chunk[i:]
 		}
 	}
+}
 
-	return
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
 }
 
+func (c *connLogger) openSpill() error {
+	f, err := os.OpenFile(c.SpillFile, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// 上次未发送的消息在重连后发送
+	c.spill, c.spillOff, c.spillEnd = f, 0, fi.Size()
+	return nil
+}
+
+// 网络日志始终发送结构体
+func (c *connLogger) structured() bool {
+	return true
+}
+
+// Flush 等待队列中的消息发送完，最多等待connDefaultTimeout
+func (c *connLogger) Flush() error {
+	if c.stop == nil {
+		return nil
+	}
+	c.wake()
+	deadline := time.Now().Add(connDefaultTimeout)
+	for c.pending() {
+		if time.Now().After(deadline) {
+			s := c.Stats()
+			return fmt.Errorf("conn logger: %d messages and %d spilled bytes not sent", s.Queued, s.Spilled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// Destroy 停止后台发送，已连接时尽量发送剩余的消息，未发送的消息保存到spillFile
 func (c *connLogger) Destroy() {
+	c.shutdown()
+}
+
+func (c *connLogger) shutdown() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
 	if c.innerWriter != nil {
-		c.innerWriter.Close()
+		if conn, ok := c.innerWriter.(net.Conn); ok {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+		}
+		if err := c.flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "conn logger flush error:%v\n", err)
+		}
+		c.closeConn()
 	}
+	c.Lock()
+	defer c.Unlock()
+	if c.spill != nil {
+		c.persist()
+		c.spill.Close()
+		c.spill = nil
+	} else {
+		c.dropped += uint64(len(c.queue))
+	}
+	c.queue = nil
+	c.spillOff, c.spillEnd = 0, 0
 }
 
-func (c *connLogger) connect() error {
+// persist 将内存中和spillFile中未发送的消息按顺序写回spillFile
+func (c *connLogger) persist() {
+	if len(c.queue) == 0 && c.spillOff == 0 {
+		return
+	}
+	rest := make([]byte, c.spillEnd-c.spillOff)
+	if _, err := c.spill.ReadAt(rest, c.spillOff); err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "conn logger spill error:%v\n", err)
+		return
+	}
+	data := bytes.Join(c.queue, nil)
+	data = append(data, rest...)
+	c.spill.Truncate(0)
+	if _, err := c.spill.WriteAt(data, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "conn logger spill error:%v\n", err)
+	}
+}
+
+func (c *connLogger) closeConn() {
 	if c.innerWriter != nil {
 		c.innerWriter.Close()
 		c.innerWriter = nil
 	}
+	atomic.StoreInt32(&c.connected, 0)
+}
+
+func (c *connLogger) connect() error {
+	c.closeConn()
 	addrs := strings.Split(c.Addr, ";")
+	var errs []string
 	for _, addr := range addrs {
-		conn, err := net.Dial(c.Net, addr)
+		conn, err := c.dial(addr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "net.Dial error:%v\n", err)
+			errs = append(errs, err.Error())
 			continue
-			//return err
 		}
 
 		if tcpConn, ok := conn.(*net.TCPConn); ok {
 			tcpConn.SetKeepAlive(true)
 		}
 		c.innerWriter = conn
+		atomic.StoreInt32(&c.connected, 1)
 		return nil
 	}
-	return fmt.Errorf("hava no valid logs service addr:%v", c.Addr)
+	return fmt.Errorf("hava no valid logs service addr:%v %s", c.Addr, strings.Join(errs, "; "))
 }
 
-func (c *connLogger) needToConnectOnMsg() bool {
-	if c.Reconnect {
-		c.Reconnect = false
-		return true
-	}
-
-	if c.innerWriter == nil {
-		return true
+func (c *connLogger) dial(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: connDefaultTimeout, KeepAlive: 30 * time.Second}
+	if c.tlsConfig == nil {
+		return dialer.Dial(c.Net, addr)
 	}
-
-	if c.illNetFlag {
-		return true
+	if !strings.HasPrefix(c.Net, "tcp") {
+		return nil, errors.New("tls requires net tcp")
 	}
-	return c.ReconnectOnMsg
+	return tls.DialWithDialer(dialer, c.Net, addr, c.tlsConfig)
 }
 
-func (c *connLogger) println(when time.Time, msg *loginfo) error {
+// Stats 返回发送统计
+func (c *connLogger) Stats() ConnStats {
 	c.Lock()
 	defer c.Unlock()
-	ss, err := json.Marshal(msg)
-	if err != nil {
-		return err
+	return ConnStats{
+		Sent:      atomic.LoadUint64(&c.sent),
+		Dropped:   c.dropped,
+		Queued:    len(c.queue),
+		Spilled:   c.spillEnd - c.spillOff,
+		Connected: atomic.LoadInt32(&c.connected) == 1,
 	}
-	_, err = c.innerWriter.Write(append(ss, '\n'))
+}
 
-	//返回err，解决日志系统网络异常后的自动重连
-	return err
+// GetConnStats returns the stats of the conn adapter of the default logger
+func GetConnStats() ConnStats {
+	c, ok := defaultLogger.adapter(AdapterConn).(*connLogger)
+	if !ok {
+		return ConnStats{}
+	}
+	return c.Stats()
 }
 
 func init() {
-	Register(AdapterConn, &connLogger{LogLevel: LevelTrace})
+	Register(AdapterConn, func() Logger {
+		return &connLogger{LogLevel: LevelTrace}
+	})
 }
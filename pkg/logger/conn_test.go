@@ -1,7 +1,23 @@
 package logger
 
 import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestConn(t *testing.T) {
@@ -9,3 +25,230 @@ func TestConn(t *testing.T) {
 	log.SetLogger("conn", `{"net":"tcp","addr":"10.1.55.10:1024"}`)
 	log.Info("this is informational to net")
 }
+
+// testCerts 在dir中生成CA、服务端证书和客户端证书，返回服务端的tls配置
+func testCerts(t *testing.T, dir string) *tls.Config {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mts test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	issue := func(name string, usage x509.ExtKeyUsage) tls.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600)
+		ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)
+		cert, _ := tls.X509KeyPair(certPEM, keyPEM)
+		return cert
+	}
+	ioutil.WriteFile(filepath.Join(dir, "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)
+	server := issue("localhost", x509.ExtKeyUsageServerAuth)
+	issue("client", x509.ExtKeyUsageClientAuth)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+// logServer 接收日志，每行一条
+type logServer struct {
+	l     net.Listener
+	lines chan string
+}
+
+func startLogServer(t *testing.T, addr string, conf *tls.Config) *logServer {
+	var l net.Listener
+	var err error
+	if conf != nil {
+		l, err = tls.Listen("tcp", addr, conf)
+	} else {
+		l, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &logServer{l: l, lines: make(chan string, 100)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewScanner(conn)
+				for r.Scan() {
+					var m loginfo
+					if json.Unmarshal(r.Bytes(), &m) == nil {
+						s.lines <- m.Content
+					}
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *logServer) expect(t *testing.T, want ...string) {
+	for _, w := range want {
+		select {
+		case got := <-s.lines:
+			if got != w {
+				t.Fatalf("got %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", w)
+		}
+	}
+}
+
+// freeAddr 返回一个当前未监听的本地地址
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func send(c *connLogger, msgs ...string) {
+	for _, m := range msgs {
+		c.LogWrite(time.Now(), &loginfo{Level: "INFO", Content: m}, LevelInformational)
+	}
+}
+
+func TestConnTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "conn")
+	defer os.RemoveAll(dir)
+	s := startLogServer(t, "127.0.0.1:0", testCerts(t, dir))
+	defer s.l.Close()
+	_, port, _ := net.SplitHostPort(s.l.Addr().String())
+
+	c := &connLogger{LogLevel: LevelTrace}
+	err := c.Init(fmt.Sprintf(`{"net":"tcp","addr":"localhost:%s","tls":true,"caFile":%q,"certFile":%q,"keyFile":%q}`,
+		port, filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Destroy()
+	send(c, "hello", "tls")
+	s.expect(t, "hello", "tls")
+	if st := c.Stats(); st.Sent != 2 || !st.Connected {
+		t.Fatalf("stats %+v", st)
+	}
+
+	// 未配置客户端证书时服务端拒绝连接
+	bad := &connLogger{LogLevel: LevelTrace}
+	bad.Init(fmt.Sprintf(`{"net":"tcp","addr":"localhost:%s","tls":true,"caFile":%q,"minBackoff":"10ms"}`,
+		port, filepath.Join(dir, "ca.crt")))
+	send(bad, "rejected")
+	time.Sleep(100 * time.Millisecond)
+	bad.Destroy()
+	select {
+	case got := <-s.lines:
+		t.Fatalf("message without client cert received: %s", got)
+	default:
+	}
+}
+
+func TestConnReconnect(t *testing.T) {
+	addr := freeAddr(t)
+	c := &connLogger{LogLevel: LevelTrace}
+	if err := c.Init(fmt.Sprintf(`{"net":"tcp","addr":%q,"minBackoff":"10ms","maxBackoff":"50ms"}`, addr)); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Destroy()
+	send(c, "1", "2", "3")
+	time.Sleep(50 * time.Millisecond)
+	if st := c.Stats(); st.Queued != 3 || st.Connected {
+		t.Fatalf("stats %+v", st)
+	}
+
+	s := startLogServer(t, addr, nil)
+	s.expect(t, "1", "2", "3")
+	s.l.Close()
+}
+
+func TestConnSpill(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "conn")
+	defer os.RemoveAll(dir)
+	addr := freeAddr(t)
+	config := fmt.Sprintf(`{"net":"tcp","addr":%q,"minBackoff":"10ms","maxBackoff":"20ms","spool":2,"spillFile":%q}`,
+		addr, filepath.Join(dir, "spill"))
+	c := &connLogger{LogLevel: LevelTrace}
+	if err := c.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	send(c, "1", "2", "3", "4")
+	if st := c.Stats(); st.Queued != 2 || st.Spilled == 0 || st.Dropped != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+	// 关闭后未发送的消息保存在spillFile中，重新初始化后发送
+	c.Destroy()
+	c = &connLogger{LogLevel: LevelTrace}
+	if err := c.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Destroy()
+	send(c, "5")
+
+	s := startLogServer(t, addr, nil)
+	defer s.l.Close()
+	s.expect(t, "1", "2", "3", "4", "5")
+	time.Sleep(50 * time.Millisecond)
+	if st := c.Stats(); st.Spilled != 0 || st.Queued != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestConnDropOldest(t *testing.T) {
+	c := &connLogger{LogLevel: LevelTrace}
+	if err := c.Init(fmt.Sprintf(`{"net":"tcp","addr":%q,"minBackoff":"1s","spool":2}`, freeAddr(t))); err != nil {
+		t.Fatal(err)
+	}
+	send(c, "1", "2", "3", "4", "5")
+	c.Lock()
+	var queued []string
+	for _, line := range c.queue {
+		var m loginfo
+		json.Unmarshal(line, &m)
+		queued = append(queued, m.Content)
+	}
+	c.Unlock()
+	st := c.Stats()
+	// 没有spillFile时关闭后未发送的消息也计入丢弃
+	c.Destroy()
+	if st.Dropped != 3 || fmt.Sprint(queued) != "[4 5]" || c.Stats().Dropped != 5 {
+		t.Fatalf("stats %+v queued %v", st, queued)
+	}
+}
@@ -2,8 +2,8 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
-	"runtime"
 	"sync"
 	"time"
 )
@@ -32,9 +32,17 @@ var colors = []brush{
 
 type consoleLogger struct {
 	sync.Mutex
-	Level    string `json:"level"`
-	Colorful bool   `json:"color"`
-	LogLevel int
+	Level      string `json:"level"`
+	Colorful   bool   `json:"color"`                // 输出为终端且未设置NO_COLOR时按等级显示颜色
+	ForceColor bool   `json:"forceColor,omitempty"` // 总是显示颜色
+	Output     string `json:"output,omitempty"`     // stdout或stderr，默认stdout
+	Format     string `json:"format,omitempty"`     // text或json，默认text
+	Layout     string `json:"layout,omitempty"`     // 文本格式的布局模板，见layout
+	LogLevel   int
+
+	out    *os.File
+	color  bool
+	layout *layout
 }
 
 func (c *consoleLogger) Init(jsonConfig string) error {
@@ -46,33 +54,66 @@ func (c *consoleLogger) Init(jsonConfig string) error {
 	}
 
 	err := json.Unmarshal([]byte(jsonConfig), c)
-	if runtime.GOOS == "windows" {
-		c.Colorful = false
+	if err != nil {
+		return err
+	}
+	switch c.Output {
+	case "", "stdout":
+		c.out = os.Stdout
+	case "stderr":
+		c.out = os.Stderr
+	default:
+		return fmt.Errorf("logger: unknown console output %s", c.Output)
+	}
+	c.color = c.ForceColor || (c.Colorful && colorEnabled(c.out))
+	c.layout = nil
+	if c.Layout != "" {
+		if c.layout, err = parseLayout(c.Layout); err != nil {
+			return err
+		}
 	}
 
 	if l, ok := LevelMap[c.Level]; ok {
 		c.LogLevel = l
-		return nil
 	}
-
-	return err
+	return nil
 }
 
 func (c *consoleLogger) LogWrite(when time.Time, msgText interface{}, level int) error {
 	if level > c.LogLevel {
 		return nil
 	}
-	msg, ok := msgText.(string)
-	if !ok {
+	var msg string
+	switch m := msgText.(type) {
+	case string:
+		msg = m
+	case *loginfo:
+		// json格式不加颜色，便于日志采集
+		c.printlnConsole(when, m.jsonLine())
+		return nil
+	default:
 		return nil
 	}
-	if c.Colorful {
+	if c.color {
 		msg = colors[level](msg)
 	}
 	c.printlnConsole(when, msg)
 	return nil
 }
 
+func (c *consoleLogger) structured() bool {
+	return c.Format == FormatJSON
+}
+
+func (c *consoleLogger) textLayout() *layout {
+	return c.layout
+}
+
+// Flush 控制台直接输出，没有缓冲
+func (c *consoleLogger) Flush() error {
+	return nil
+}
+
 func (c *consoleLogger) Destroy() {
 
 }
@@ -80,12 +121,18 @@ func (c *consoleLogger) Destroy() {
 func (c *consoleLogger) printlnConsole(when time.Time, msg string) {
 	c.Lock()
 	defer c.Unlock()
-	os.Stdout.Write(append([]byte(msg), '\n'))
+	out := c.out
+	if out == nil {
+		out = os.Stdout
+	}
+	out.Write(append([]byte(msg), '\n'))
 }
 
 func init() {
-	Register(AdapterConsole, &consoleLogger{
-		LogLevel: LevelDebug,
-		Colorful: runtime.GOOS != "windows",
+	Register(AdapterConsole, func() Logger {
+		return &consoleLogger{
+			LogLevel: LevelDebug,
+			Colorful: true,
+		}
 	})
 }
@@ -0,0 +1,33 @@
+package logger
+
+import "context"
+
+// 上下文logger常用的字段名
+const (
+	KeySession  = "session"
+	KeyPlatform = "platform"
+	KeySku      = "skuId"
+	KeyWorker   = "worker"
+)
+
+type ctxKey struct{}
+
+// NewContext 返回携带l的ctx，之后通过FromContext取出的logger输出的日志都带有l的字段
+func NewContext(ctx context.Context, l *LocalLogger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext 返回ctx中的logger，没有时返回默认logger的子logger
+func FromContext(ctx context.Context) *LocalLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*LocalLogger); ok {
+			return l
+		}
+	}
+	return defaultLogger.With()
+}
+
+// ContextWith 在ctx中logger的基础上追加kv字段，返回携带新logger的ctx
+func ContextWith(ctx context.Context, kv ...interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(kv...))
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	log.SetLogPath(true)
+	ctx := NewContext(context.Background(), log.With(KeySession, "a1b2", KeyPlatform, "jd", KeySku, "100012043978"))
+	FromContext(ctx).Info("正在访问抢购连接......")
+
+	// 各worker的ctx互不影响
+	w1 := ContextWith(ctx, KeyWorker, 1)
+	w2 := ContextWith(ctx, KeyWorker, 2)
+	FromContext(w2).Info("worker %d", 2)
+	FromContext(w1).Warn("worker 1")
+
+	msgs := gate.received()
+	want := []string{
+		"正在访问抢购连接...... session=a1b2 platform=jd skuId=100012043978",
+		"worker 2 session=a1b2 platform=jd skuId=100012043978 worker=2",
+		"worker 1 session=a1b2 platform=jd skuId=100012043978 worker=1",
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+	for i, m := range msgs {
+		if !strings.HasSuffix(m, want[i]) || !strings.Contains(m, "[context_test.go:") {
+			t.Fatalf("got %q, want %q", m, want[i])
+		}
+	}
+}
+
+func TestContextDefault(t *testing.T) {
+	if FromContext(context.Background()).loggerCore != defaultLogger.loggerCore {
+		t.Fatal("empty context should use the default logger")
+	}
+	if FromContext(nil).loggerCore != defaultLogger.loggerCore {
+		t.Fatal("nil context should use the default logger")
+	}
+}
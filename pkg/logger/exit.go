@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	exitLock    sync.Mutex
+	exitHooks   []func()
+	exitTimeout = defaultFlushTimeout
+)
+
+// osExit 测试中替换，避免退出测试进程
+var osExit = os.Exit
+
+// RegisterExitHook 注册Fatal或Exit退出前执行的函数，按注册的逆序执行，每个函数只执行一次
+func RegisterExitHook(f func()) {
+	exitLock.Lock()
+	defer exitLock.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// SetExitTimeout 设置退出前执行hook和写出日志的最长时间，默认3s
+func SetExitTimeout(d time.Duration) {
+	exitLock.Lock()
+	defer exitLock.Unlock()
+	exitTimeout = d
+}
+
+// Exit 执行退出hook，写出默认logger的日志并关闭适配器后以code退出进程
+func Exit(code int) {
+	shutdown(defaultLogger)
+	osExit(code)
+}
+
+// shutdown 在exitTimeout内执行退出hook，然后关闭l和默认logger，超时后放弃剩余的日志
+func shutdown(l *LocalLogger) {
+	exitLock.Lock()
+	hooks, timeout := exitHooks, exitTimeout
+	exitHooks = nil
+	exitLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(hooks) - 1; i >= 0; i-- {
+			runExitHook(hooks[i])
+		}
+		if l.loggerCore != defaultLogger.loggerCore {
+			l.Close()
+		}
+		defaultLogger.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		fmt.Fprintf(os.Stderr, "logger: exit timeout after %v, some logs may be lost\n", timeout)
+	}
+}
+
+// runExitHook 执行hook，hook中的panic不影响后续的hook和日志写出
+func runExitHook(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "logger: exit hook panic: %v\n", r)
+		}
+	}()
+	f()
+}
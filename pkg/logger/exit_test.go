@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExit 替换osExit，返回记录退出码的函数
+func fakeExit(t *testing.T) func() int {
+	code := -1
+	osExit = func(c int) { code = c }
+	t.Cleanup(func() {
+		osExit = os.Exit
+		SetExitTimeout(defaultFlushTimeout)
+		// shutdown会关闭默认logger
+		defaultLogger.SetLogger(AdapterConsole)
+	})
+	return func() int { return code }
+}
+
+func TestFatalFlush(t *testing.T) {
+	exitCode := fakeExit(t)
+	r := &recorder{}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	log := newGateLogger(t, &AsyncConfig{})
+	gate.set(nil, time.Millisecond)
+	log.SetLogger(AdapterHTTP, fmt.Sprintf(`{"url":%q,"format":"loki","interval":"1h"}`, s.URL))
+
+	var order []string
+	RegisterExitHook(func() { order = append(order, "first") })
+	RegisterExitHook(func() { panic("hook failed") })
+	RegisterExitHook(func() {
+		order = append(order, "last")
+		log.Warn("exit hook")
+	})
+	for i := 0; i < 20; i++ {
+		log.Info("attempt %d", i)
+	}
+	log.Fatal("配置错误")
+
+	if exitCode() != 1 || fmt.Sprint(order) != "[last first]" {
+		t.Fatalf("exit code %d, hooks %v", exitCode(), order)
+	}
+	msgs := gate.received()
+	if len(msgs) != 22 || !strings.HasSuffix(msgs[20], "###Exec Panic:配置错误") || !strings.HasSuffix(msgs[21], "exit hook") {
+		t.Fatalf("unexpected output %d %q", len(msgs), msgs)
+	}
+	if b := r.received(); len(b) != 1 || !strings.Contains(string(b[0]), "exit hook") {
+		t.Fatalf("http logs not sent: %q", b)
+	}
+
+	// 每个hook只执行一次
+	Exit(2)
+	if exitCode() != 2 || len(order) != 2 {
+		t.Fatalf("exit code %d, hooks %v", exitCode(), order)
+	}
+}
+
+func TestExitTimeout(t *testing.T) {
+	exitCode := fakeExit(t)
+	SetExitTimeout(50 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	RegisterExitHook(func() { <-block })
+	start := time.Now()
+	Exit(3)
+	if exitCode() != 3 || time.Since(start) > time.Second {
+		t.Fatalf("exit code %d after %v", exitCode(), time.Since(start))
+	}
+}
+
+func TestFlushAdapters(t *testing.T) {
+	r := &recorder{}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetLogger(AdapterHTTP, fmt.Sprintf(`{"url":%q,"format":"loki","interval":"1h"}`, s.URL))
+	defer log.Close()
+	log.Info("1")
+	log.Info("2")
+	if err := log.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b := r.received(); len(b) != 1 || strings.Count(string(b[0]), `"1`) == 0 {
+		t.Fatalf("unexpected requests %q", b)
+	}
+
+	r.Lock()
+	r.status = []int{400}
+	r.Unlock()
+	log.Info("3")
+	if err := log.Flush(); err == nil || !strings.Contains(err.Error(), "flush http") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSetLoggerError(t *testing.T) {
+	if err := SetLogger("no-such-config.json"); err == nil {
+		t.Fatal("missing config file accepted")
+	}
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 适配器输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Field is one key value pair attached to a log message
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields keep the order in which they were added
+type Fields []Field
+
+// makeFields 将key, value, key, value...转换为Fields，key不是字符串时使用fmt格式化，
+// 缺少value时值为nil
+func makeFields(kv ...interface{}) Fields {
+	fs := make(Fields, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			k = fmt.Sprint(kv[i])
+		}
+		var v interface{}
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		fs = append(fs, Field{Key: k, Value: v})
+	}
+	return fs
+}
+
+// with 返回追加了kv的新Fields，同名key会被替换
+func (fs Fields) with(add Fields) Fields {
+	out := make(Fields, 0, len(fs)+len(add))
+	out = append(out, fs...)
+NEXT:
+	for _, a := range add {
+		for i := range out {
+			if out[i].Key == a.Key {
+				out[i].Value = a.Value
+				continue NEXT
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// fieldValue 返回适合json编码的值，字符串会经过Redact
+func fieldValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return x
+	case Secret:
+		return x.String()
+	case time.Duration:
+		return x.String()
+	case error:
+		return Redact(x.Error())
+	case fmt.Stringer:
+		return Redact(x.String())
+	case string:
+		return Redact(x)
+	case json.Marshaler:
+		return x
+	}
+	return Redact(fmt.Sprint(v))
+}
+
+// Text 返回 key=value key=value 格式，包含空格等字符的值会加引号
+func (fs Fields) Text() string {
+	var b strings.Builder
+	for i, f := range fs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		v := fmt.Sprint(fieldValue(f.Value))
+		if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler, output an object in field order
+func (fs Fields) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	if err := fs.writeJSON(&b, false); err != nil {
+		return nil, err
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// writeJSON 写入 "key":value 对，comma为true时在第一个字段前加逗号
+func (fs Fields) writeJSON(b *bytes.Buffer, comma bool) error {
+	for i, f := range fs {
+		if comma || i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.Key)
+		v, err := json.Marshal(fieldValue(f.Value))
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(f.Value))
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	return nil
+}
+
+// jsonLine 将日志编码为一行json，用于console和file的json格式
+func (m *loginfo) jsonLine() string {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	t, _ := json.Marshal(m.Time)
+	b.Write(t)
+	b.WriteString(`,"level":"` + m.Level + `"`)
+	if m.Path != "" {
+		p, _ := json.Marshal(m.Path)
+		b.WriteString(`,"path":`)
+		b.Write(p)
+	}
+	msg, _ := json.Marshal(m.Content)
+	b.WriteString(`,"msg":`)
+	b.Write(msg)
+	m.Fields.writeJSON(&b, true)
+	b.WriteByte('}')
+	return b.String()
+}
+
+// structured is implemented by adapters which want the *loginfo instead of
+// the formatted text line
+type structured interface {
+	structured() bool
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithText(t *testing.T) {
+	defer os.Remove("fields.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetLogger(AdapterFile, `{"filename":"fields.log"}`)
+	child := log.With("worker", 1, "skuId", "100012043978")
+	child.With("stage", "submit", "worker", 2).Info("提交订单", "失败")
+	child.Info("重试")
+	log.Info("no fields")
+	log.Close()
+
+	b, _ := ioutil.ReadFile("fields.log")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected output %s", b)
+	}
+	if !strings.HasSuffix(lines[0], "提交订单 失败 worker=2 skuId=100012043978 stage=submit") {
+		t.Fatalf("line 0: %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "重试 worker=1 skuId=100012043978") {
+		t.Fatalf("line 1: %s", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "no fields") {
+		t.Fatalf("line 2: %s", lines[2])
+	}
+}
+
+func TestWithJSON(t *testing.T) {
+	defer os.Remove("fields.json.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetLogger(AdapterFile, `{"filename":"fields.json.log","format":"json"}`)
+	log.With("worker", 3, "latencyMs", 12.5, "cost", 2*time.Second, "note", "a b").Warn("请求完成")
+	log.Close()
+	// 恢复默认格式，适配器为全局共享
+	log.SetLogger(AdapterFile, `{"filename":"fields.json.log","format":"text"}`)
+	log.Close()
+
+	b, _ := ioutil.ReadFile("fields.json.log")
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.Split(string(b), "\n")[0]), &m); err != nil {
+		t.Fatal(err, string(b))
+	}
+	if m["level"] != "WARN" || m["msg"] != "请求完成" || m["note"] != "a b" || m["worker"] != float64(3) ||
+		m["latencyMs"] != 12.5 || m["cost"] != "2s" {
+		t.Fatalf("unexpected json %v", m)
+	}
+}
+
+func TestFieldsText(t *testing.T) {
+	fs := makeFields("a", "x y", "b", "", 1, Secret("123456"), "c")
+	if got := fs.Text(); got != `a="x y" b="" 1=****** c=<nil>` {
+		t.Fatalf("text %s", got)
+	}
+}
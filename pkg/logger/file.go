@@ -2,12 +2,16 @@ package logger
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,14 +22,21 @@ type fileLogger struct {
 	sync.RWMutex
 	fileWriter *os.File
 
-	Filename   string `json:"filename"`
-	Append     bool   `json:"append"`
-	MaxLines   int    `json:"maxlines"`
-	MaxSize    int    `json:"maxsize"`
-	Daily      bool   `json:"daily"`
-	MaxDays    int64  `json:"maxdays"`
-	Level      string `json:"level"`
-	PermitMask string `json:"permit"`
+	Filename     string `json:"filename"`
+	Append       bool   `json:"append"`
+	MaxLines     int    `json:"maxlines"`
+	MaxSize      int    `json:"maxsize"`
+	Daily        bool   `json:"daily"`
+	Hourly       bool   `json:"hourly,omitempty"` // 每小时切分，当append=true时有效
+	MaxDays      int64  `json:"maxdays"`
+	MaxFiles     int    `json:"maxfiles,omitempty"`     // 最多保留的切分文件数，0为不限制
+	MaxTotalSize int    `json:"maxtotalsize,omitempty"` // 切分文件最多占用的空间，单位MB，0为不限制
+	Compress     bool   `json:"compress,omitempty"`     // 后台将切分后的文件压缩为.gz
+	Symlink      bool   `json:"symlink,omitempty"`      // 写入带日期的文件，filename为指向当前文件的软链接
+	Level        string `json:"level"`
+	PermitMask   string `json:"permit"`
+	Format       string `json:"format,omitempty"` // text或json，默认text
+	Layout       string `json:"layout,omitempty"` // 文本格式的布局模板，见layout
 
 	LogLevel             int
 	maxSizeCurSize       int
@@ -33,19 +44,30 @@ type fileLogger struct {
 	dailyOpenDate        int
 	dailyOpenTime        time.Time
 	fileNameOnly, suffix string
+	current              string // symlink=true时正在写入的文件
+	layout               *layout
+	bg                   sync.WaitGroup // 后台压缩和清理
+	bgLock               sync.Mutex
 }
 
 // Init file logger with json config.
 // jsonConfig like:
-//	{
-//	"filename":"log/app.log",
-//	"maxlines":10000,
-//	"maxsize":1024,
-//	"daily":true,
-//	"maxdays":15,
-//	"rotate":true,
-//  	"permit":"0600"
-//	}
+//
+//		{
+//		"filename":"log/app.log",
+//		"maxlines":10000,
+//		"maxsize":1024,
+//		"daily":true,
+//		"maxdays":15,
+//		"rotate":true,
+//	 	"permit":"0600",
+//		"hourly":false,
+//		"maxfiles":30,
+//		"maxtotalsize":512,
+//		"compress":true,
+//		"symlink":false,
+//		"layout":"{time:15:04:05.000} {level} {caller} {msg} {fields}"
+//		}
 func (f *fileLogger) Init(jsonConfig string) error {
 	// fmt.Printf("fileLogger Init:%s\n", jsonConfig)
 	if len(jsonConfig) == 0 {
@@ -58,6 +80,11 @@ func (f *fileLogger) Init(jsonConfig string) error {
 	if len(f.Filename) == 0 {
 		return errors.New("jsonconfig must have filename")
 	}
+	if f.Layout != "" {
+		if f.layout, err = parseLayout(f.Layout); err != nil {
+			return err
+		}
+	}
 	f.suffix = filepath.Ext(f.Filename)
 	f.fileNameOnly = strings.TrimSuffix(f.Filename, f.suffix)
 	f.MaxSize *= 1024 * 1024 // 将单位转换成MB
@@ -67,35 +94,56 @@ func (f *fileLogger) Init(jsonConfig string) error {
 	if l, ok := LevelMap[f.Level]; ok {
 		f.LogLevel = l
 	}
+	if f.Symlink {
+		if err = f.initSymlink(); err != nil {
+			return err
+		}
+	}
 	err = f.newFile()
+	if err == nil && f.Symlink {
+		err = f.link()
+	}
 	return err
 }
 
-func (f *fileLogger) needCreateFresh(size int, day int) bool {
+func (f *fileLogger) needCreateFresh(size int, when time.Time) bool {
 	return (f.MaxLines > 0 && f.maxLinesCurLines >= f.MaxLines) ||
 		(f.MaxSize > 0 && f.maxSizeCurSize+size >= f.MaxSize) ||
-		(f.Daily && day != f.dailyOpenDate)
+		(f.Daily && when.Day() != f.dailyOpenDate) ||
+		(f.Hourly && (when.Day() != f.dailyOpenDate || when.Hour() != f.dailyOpenTime.Hour()))
 
 }
 
+// path 返回正在写入的文件
+func (f *fileLogger) path() string {
+	if f.Symlink {
+		return f.current
+	}
+	return f.Filename
+}
+
 // WriteMsg write logger message into file.
 func (f *fileLogger) LogWrite(when time.Time, msgText interface{}, level int) error {
-	msg, ok := msgText.(string)
-	if !ok {
+	var msg string
+	switch m := msgText.(type) {
+	case string:
+		msg = m
+	case *loginfo:
+		msg = m.jsonLine()
+	default:
 		return nil
 	}
 	if level > f.LogLevel {
 		return nil
 	}
 
-	day := when.Day()
 	msg += "\n"
 	if f.Append {
 		f.RLock()
-		if f.needCreateFresh(len(msg), day) {
+		if f.needCreateFresh(len(msg), when) {
 			f.RUnlock()
 			f.Lock()
-			if f.needCreateFresh(len(msg), day) {
+			if f.needCreateFresh(len(msg), when) {
 				if err := f.createFreshFile(when); err != nil {
 					fmt.Fprintf(os.Stderr, "createFreshFile(%q): %s\n", f.Filename, err)
 				}
@@ -116,16 +164,56 @@ func (f *fileLogger) LogWrite(when time.Time, msgText interface{}, level int) er
 	return err
 }
 
+// LogWriteBatch 异步输出时将多条日志合并为一次写入，按批次判断是否需要切分文件
+func (f *fileLogger) LogWriteBatch(recs []logRecord) error {
+	var buf bytes.Buffer
+	var when time.Time
+	lines := 0
+	for _, r := range recs {
+		if r.level > f.LogLevel {
+			continue
+		}
+		switch m := r.msg.(type) {
+		case string:
+			buf.WriteString(m)
+		case *loginfo:
+			buf.WriteString(m.jsonLine())
+		default:
+			continue
+		}
+		buf.WriteByte('\n')
+		when = r.when
+		lines++
+	}
+	if lines == 0 {
+		return nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if f.Append && f.needCreateFresh(buf.Len(), when) {
+		if err := f.createFreshFile(when); err != nil {
+			fmt.Fprintf(os.Stderr, "createFreshFile(%q): %s\n", f.Filename, err)
+		}
+	}
+	_, err := f.fileWriter.Write(buf.Bytes())
+	if err == nil {
+		f.maxLinesCurLines += lines
+		f.maxSizeCurSize += buf.Len()
+	}
+	return err
+}
+
 func (f *fileLogger) createLogFile() (*os.File, error) {
 	// Open the log file
 	perm, err := strconv.ParseInt(f.PermitMask, 8, 64)
 	if err != nil {
 		return nil, err
 	}
-	fd, err := os.OpenFile(f.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(perm))
+	fd, err := os.OpenFile(f.path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(perm))
 	if err == nil {
 		// Make sure file perm is user set perm cause of `os.OpenFile` will obey umask
-		os.Chmod(f.Filename, os.FileMode(perm))
+		os.Chmod(f.path(), os.FileMode(perm))
 	}
 	return fd, err
 }
@@ -159,7 +247,7 @@ func (f *fileLogger) newFile() error {
 }
 
 func (f *fileLogger) lines() (int, error) {
-	fd, err := os.Open(f.Filename)
+	fd, err := os.Open(f.path())
 	if err != nil {
 		return 0, err
 	}
@@ -185,11 +273,33 @@ func (f *fileLogger) lines() (int, error) {
 	return count, nil
 }
 
+// segmentName 返回t对应的第一个未使用的切分文件名，如 xx.2013-01-01.001.log，
+// hourly=true时为 xx.2013-01-01-15.001.log
+func (f *fileLogger) segmentName(t time.Time) string {
+	layout := "2006-01-02"
+	if f.Hourly {
+		layout = "2006-01-02-15"
+	}
+	for num := 1; ; num++ {
+		name := f.fileNameOnly + fmt.Sprintf(".%s.%03d%s", t.Format(layout), num, f.suffix)
+		if !exist(name) && !exist(name+".gz") && name != f.current {
+			return name
+		}
+	}
+}
+
+func exist(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
 // new file name like  xx.2013-01-01.001.log
 func (f *fileLogger) createFreshFile(logTime time.Time) error {
+	if f.Symlink {
+		return f.rotateSymlink(logTime)
+	}
 	// file exists
 	// Find the next available number
-	num := 1
 	fName := ""
 	rotatePerm, err := strconv.ParseInt(f.PermitMask, 8, 64)
 	if err != nil {
@@ -202,20 +312,10 @@ func (f *fileLogger) createFreshFile(logTime time.Time) error {
 		goto RESTART_LOGGER
 	}
 	// 日期变了， 说明跨天，重命名时需要保存为昨天的日期
-	if f.dailyOpenDate != logTime.Day() {
-		for ; err == nil && num <= 999; num++ {
-			fName = f.fileNameOnly + fmt.Sprintf(".%s.%03d%s", f.dailyOpenTime.Format("2006-01-02"), num, f.suffix)
-			_, err = os.Lstat(fName)
-		}
+	if f.dailyOpenDate != logTime.Day() || (f.Hourly && f.dailyOpenTime.Hour() != logTime.Hour()) {
+		fName = f.segmentName(f.dailyOpenTime)
 	} else { //如果仅仅是文件大小或行数达到了限制，仅仅变更后缀序号即可
-		for ; err == nil && num <= 999; num++ {
-			fName = f.fileNameOnly + fmt.Sprintf(".%s.%03d%s", logTime.Format("2006-01-02"), num, f.suffix)
-			_, err = os.Lstat(fName)
-		}
-	}
-
-	if err == nil {
-		return fmt.Errorf("Cannot find free log number to rename %s", f.Filename)
+		fName = f.segmentName(logTime)
 	}
 	f.fileWriter.Close()
 
@@ -227,6 +327,7 @@ func (f *fileLogger) createFreshFile(logTime time.Time) error {
 	err = os.Rename(f.Filename, fName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "os.Rename %s to %s err:%s\n", f.Filename, fName, err.Error())
+		fName = ""
 		goto RESTART_LOGGER
 	}
 
@@ -235,7 +336,7 @@ func (f *fileLogger) createFreshFile(logTime time.Time) error {
 RESTART_LOGGER:
 
 	startLoggerErr := f.newFile()
-	go f.deleteOldLog()
+	f.afterRotate(fName)
 
 	if startLoggerErr != nil {
 		return fmt.Errorf("Rotate StartLogger: %s", startLoggerErr)
@@ -246,41 +347,188 @@ RESTART_LOGGER:
 	return nil
 }
 
-func (f *fileLogger) deleteOldLog() {
-	dir := filepath.Dir(f.Filename)
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) (returnErr error) {
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Fprintf(os.Stderr, "Unable to delete old log '%s', error: %v\n", path, r)
+// initSymlink 确定要写入的文件，filename为普通文件时先将其切分
+func (f *fileLogger) initSymlink() error {
+	fi, err := os.Lstat(f.Filename)
+	if err == nil && fi.Mode()&os.ModeSymlink != 0 && f.Append {
+		if target, err := os.Readlink(f.Filename); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(f.Filename), target)
 			}
-		}()
-
-		if info == nil {
-			return
+			if exist(target) {
+				f.current = target
+				return nil
+			}
+		}
+	}
+	if err == nil && fi.Mode().IsRegular() {
+		if err = os.Rename(f.Filename, f.segmentName(fi.ModTime())); err != nil {
+			return err
 		}
+	}
+	f.current = f.segmentName(time.Now())
+	return nil
+}
+
+// link 将filename指向当前文件，先创建临时链接再重命名，保证filename始终存在
+func (f *fileLogger) link() error {
+	tmp := f.Filename + ".link"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(f.current), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.Filename)
+}
+
+// rotateSymlink 打开新的带日期文件并更新软链接，旧文件不需要重命名
+func (f *fileLogger) rotateSymlink(logTime time.Time) error {
+	old := f.current
+	f.current = f.segmentName(logTime)
+	if err := f.newFile(); err != nil {
+		f.current = old
+		return fmt.Errorf("Rotate StartLogger: %s", err)
+	}
+	err := f.link()
+	f.afterRotate(old)
+	if err != nil {
+		return fmt.Errorf("Rotate: %s", err)
+	}
+	return nil
+}
 
-		if f.MaxDays != -1 && !info.IsDir() && info.ModTime().Add(24*time.Hour*time.Duration(f.MaxDays)).Before(time.Now()) {
-			if strings.HasPrefix(filepath.Base(path), filepath.Base(f.fileNameOnly)) &&
-				strings.HasSuffix(filepath.Base(path), f.suffix) {
-				os.Remove(path)
+// afterRotate 在后台压缩rotated并按保留策略清理旧文件，rotated为空时仅清理
+func (f *fileLogger) afterRotate(rotated string) {
+	current := f.current
+	f.bg.Add(1)
+	go func() {
+		defer f.bg.Done()
+		f.bgLock.Lock()
+		defer f.bgLock.Unlock()
+		if f.Compress && rotated != "" {
+			if err := compressFile(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "compress %s err:%s\n", rotated, err)
 			}
 		}
-		return
+		f.deleteOldLog(current)
+	}()
+}
+
+// compressFile 将name压缩为name.gz后删除name，保留原文件的修改时间和权限
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = fi.ModTime()
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, fi.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// rotatedFiles 返回已切分的日志文件，不包括正在写入的current，按修改时间从新到旧排序
+func (f *fileLogger) rotatedFiles(current string) []os.FileInfo {
+	dir := filepath.Dir(f.Filename)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	// 只匹配segmentName生成的 xx.2013-01-01[-15].001.log[.gz]，同一目录下其他输出的文件不受影响
+	segment := regexp.MustCompile(`^` + regexp.QuoteMeta(filepath.Base(f.fileNameOnly)) +
+		`\.\d{4}-\d{2}-\d{2}(-\d{2})?\.\d{3,}` + regexp.QuoteMeta(f.suffix) + `(\.gz)?$`)
+	var files []os.FileInfo
+	for _, fi := range infos {
+		name := fi.Name()
+		path := filepath.Join(dir, name)
+		if fi.IsDir() || path == filepath.Clean(current) || !segment.MatchString(name) {
+			continue
+		}
+		files = append(files, fi)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
 	})
+	return files
+}
+
+// deleteOldLog 按maxdays、maxfiles、maxtotalsize删除切分后的文件，优先保留较新的文件
+func (f *fileLogger) deleteOldLog(current string) {
+	dir := filepath.Dir(f.Filename)
+	var total int64
+	for i, fi := range f.rotatedFiles(current) {
+		total += fi.Size()
+		expired := f.MaxDays != -1 && fi.ModTime().Add(24*time.Hour*time.Duration(f.MaxDays)).Before(time.Now())
+		if expired || (f.MaxFiles > 0 && i >= f.MaxFiles) ||
+			(f.MaxTotalSize > 0 && total > int64(f.MaxTotalSize)*1024*1024) {
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to delete old log '%s', error: %v\n", fi.Name(), err)
+			}
+		}
+	}
+}
+
+func (f *fileLogger) structured() bool {
+	return f.Format == FormatJSON
+}
+
+func (f *fileLogger) textLayout() *layout {
+	return f.layout
+}
+
+// Flush 将文件内容同步到磁盘
+func (f *fileLogger) Flush() error {
+	f.Lock()
+	defer f.Unlock()
+	if f.fileWriter == nil {
+		return nil
+	}
+	return f.fileWriter.Sync()
 }
 
 func (f *fileLogger) Destroy() {
 	f.fileWriter.Close()
+	f.bg.Wait()
 }
 
 func init() {
-	Register(AdapterFile, &fileLogger{
-		Daily:      true,
-		MaxDays:    7,
-		Append:     true,
-		LogLevel:   LevelDebug,
-		PermitMask: "0777",
-		MaxLines:   10,
-		MaxSize:    10 * 1024 * 1024,
+	Register(AdapterFile, func() Logger {
+		return &fileLogger{
+			Daily:      true,
+			MaxDays:    7,
+			Append:     true,
+			LogLevel:   LevelDebug,
+			PermitMask: "0777",
+			MaxLines:   10,
+			MaxSize:    10 * 1024 * 1024,
+		}
 	})
 }
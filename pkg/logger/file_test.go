@@ -2,9 +2,13 @@ package logger
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -137,6 +141,197 @@ func TestFileByTime(t *testing.T) {
 	fw.Destroy()
 }
 
+// newRotateLogger 在临时目录中创建独立的文件适配器，config追加在filename之后
+func newRotateLogger(t *testing.T, config string) (*fileLogger, string) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw := &fileLogger{Append: true, MaxDays: -1, LogLevel: LevelTrace, PermitMask: "0660"}
+	if err = fw.Init(fmt.Sprintf(`{"filename":%q%s}`, filepath.Join(dir, "app.log"), config)); err != nil {
+		t.Fatal(err)
+	}
+	return fw, dir
+}
+
+// rotated 返回目录中已切分的文件名
+func rotated(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range infos {
+		if fi.Name() != "app.log" {
+			names = append(names, fi.Name())
+		}
+	}
+	return names
+}
+
+func TestFileCompress(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxlines":2,"compress":true`)
+	defer os.RemoveAll(dir)
+	for i := 0; i < 5; i++ {
+		fw.LogWrite(time.Now(), fmt.Sprintf("line %d", i), LevelInformational)
+	}
+	fw.Destroy()
+	names := rotated(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("rotated files %v", names)
+	}
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".log.gz") {
+			t.Fatalf("%s is not compressed", name)
+		}
+	}
+	zf, _ := os.Open(filepath.Join(dir, names[0]))
+	defer zf.Close()
+	zr, err := gzip.NewReader(zf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(zr)
+	if string(b) != "line 0\nline 1\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
+
+func TestFileMaxFiles(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxlines":1,"maxfiles":2`)
+	defer os.RemoveAll(dir)
+	for i := 0; i < 6; i++ {
+		fw.LogWrite(time.Now(), fmt.Sprintf("line %d", i), LevelInformational)
+		// 保证修改时间不同
+		time.Sleep(10 * time.Millisecond)
+	}
+	fw.Destroy()
+	names := rotated(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("rotated files %v", names)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, names[1]))
+	if string(b) != "line 4\n" {
+		t.Fatalf("newest rotated file is %s: %q", names[1], b)
+	}
+}
+
+func TestFileMaxTotalSize(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxsize":1,"maxtotalsize":2`)
+	defer os.RemoveAll(dir)
+	msg := strings.Repeat("x", 600*1024)
+	for i := 0; i < 8; i++ {
+		fw.LogWrite(time.Now(), msg, LevelInformational)
+		time.Sleep(10 * time.Millisecond)
+	}
+	fw.Destroy()
+	var total int64
+	names := rotated(t, dir)
+	for _, name := range names {
+		fi, _ := os.Stat(filepath.Join(dir, name))
+		total += fi.Size()
+	}
+	if len(names) != 3 || total > 2*1024*1024 {
+		t.Fatalf("rotated files %v total %d", names, total)
+	}
+}
+
+func TestFileMaxDays(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxlines":1,"maxdays":1`)
+	defer os.RemoveAll(dir)
+	old := filepath.Join(dir, "app.2020-01-01.001.log")
+	other := filepath.Join(dir, "other.2020-01-01.001.log")
+	for _, name := range []string{old, other} {
+		ioutil.WriteFile(name, []byte("old\n"), 0660)
+		os.Chtimes(name, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	}
+	fw.LogWrite(time.Now(), "line 0", LevelInformational)
+	fw.LogWrite(time.Now(), "line 1", LevelInformational)
+	fw.Destroy()
+	if b, _ := exists(old); b {
+		t.Fatal("expired file not deleted")
+	}
+	if b, _ := exists(other); !b {
+		t.Fatal("file of another logger deleted")
+	}
+	if names := rotated(t, dir); len(names) != 2 {
+		t.Fatalf("rotated files %v", names)
+	}
+}
+
+func TestFileSharedDir(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxlines":1,"maxfiles":1,"maxdays":1`)
+	defer os.RemoveAll(dir)
+	// app.err.log是同一目录下另一个输出的文件，不能被当作app.log的切分文件删除
+	others := []string{"app.err.log", "app.err.2020-01-01.001.log", "app.err.2020-01-01.002.log.gz", "app.bak.log"}
+	for _, name := range others {
+		name = filepath.Join(dir, name)
+		ioutil.WriteFile(name, []byte("other\n"), 0660)
+		os.Chtimes(name, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	}
+	for i := 0; i < 4; i++ {
+		fw.LogWrite(time.Now(), fmt.Sprintf("line %d", i), LevelInformational)
+		time.Sleep(10 * time.Millisecond)
+	}
+	fw.Destroy()
+	for _, name := range others {
+		if b, _ := exists(filepath.Join(dir, name)); !b {
+			t.Fatalf("%s of another output deleted", name)
+		}
+	}
+	if names := rotated(t, dir); len(names) != len(others)+1 {
+		t.Fatalf("rotated files %v", names)
+	}
+}
+
+func TestFileHourly(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"hourly":true`)
+	defer os.RemoveAll(dir)
+	opened := time.Now().Add(-time.Hour)
+	fw.dailyOpenTime = opened
+	fw.dailyOpenDate = opened.Day()
+	fw.LogWrite(time.Now(), "this is a msg for test", LevelTrace)
+	fw.Destroy()
+	want := "app" + fmt.Sprintf(".%s.%03d", opened.Format("2006-01-02-15"), 1) + ".log"
+	if names := rotated(t, dir); len(names) != 1 || names[0] != want {
+		t.Fatalf("rotated files %v, want %s", names, want)
+	}
+}
+
+func TestFileSymlink(t *testing.T) {
+	fw, dir := newRotateLogger(t, `,"maxlines":1,"symlink":true`)
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "app.log")
+	first, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.LogWrite(time.Now(), "line 0", LevelInformational)
+	fw.LogWrite(time.Now(), "line 1", LevelInformational)
+	second, _ := os.Readlink(link)
+	if first == second || filepath.IsAbs(second) {
+		t.Fatalf("symlink not updated: %s %s", first, second)
+	}
+	if b, _ := ioutil.ReadFile(link); string(b) != "line 1\n" {
+		t.Fatalf("current file %q", b)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, first)); string(b) != "line 0\n" {
+		t.Fatalf("rotated file %q", b)
+	}
+	fw.Destroy()
+
+	// 重新打开时继续写入软链接指向的文件
+	fw = &fileLogger{Append: true, MaxDays: -1, LogLevel: LevelTrace, PermitMask: "0660"}
+	if err = fw.Init(fmt.Sprintf(`{"filename":%q,"symlink":true}`, link)); err != nil {
+		t.Fatal(err)
+	}
+	fw.LogWrite(time.Now(), "line 2", LevelInformational)
+	fw.Destroy()
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, second)); string(b) != "line 1\nline 2\n" {
+		t.Fatalf("reopened file %q", b)
+	}
+}
+
 func exists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -0,0 +1,420 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// http日志格式
+const (
+	HTTPElasticsearch = "elasticsearch" // Elasticsearch _bulk NDJSON
+	HTTPLoki          = "loki"          // Loki push api
+)
+
+// http日志默认配置
+const (
+	httpDefaultBatchSize   = 500
+	httpDefaultBatchBytes  = 1024 * 1024
+	httpDefaultInterval    = time.Second
+	httpDefaultMaxInFlight = 2
+	httpDefaultMaxRetries  = 5
+	httpDefaultQueue       = 10000
+	httpDefaultIndex       = "mts"
+)
+
+// httpLogger 按条数、大小和时间间隔将日志批量POST到Elasticsearch或Loki，
+// 失败时按指数退避重试，同时进行中的请求数不超过MaxInFlight
+type httpLogger struct {
+	sync.Mutex
+	URL         string            `json:"url"`                   // 如http://127.0.0.1:9200/_bulk或http://127.0.0.1:3100/loki/api/v1/push
+	Format      string            `json:"format"`                // elasticsearch或loki，默认elasticsearch
+	Index       string            `json:"index,omitempty"`       // Elasticsearch索引，默认mts
+	AppName     string            `json:"appName,omitempty"`     // 默认使用环境变量APPSN，未设置时为程序名
+	Headers     map[string]string `json:"headers,omitempty"`     // 附加的请求头，如Authorization
+	Level       string            `json:"level"`                 // 日志输出等级
+	BatchSize   int               `json:"batchSize,omitempty"`   // 每批最多的条数，默认500
+	BatchBytes  int               `json:"batchBytes,omitempty"`  // 每批最大字节数，默认1MB
+	Interval    string            `json:"interval,omitempty"`    // 未满一批时的发送间隔，默认1s
+	MaxInFlight int               `json:"maxInFlight,omitempty"` // 同时进行中的请求数，默认2
+	MaxRetries  int               `json:"maxRetries,omitempty"`  // 失败后的重试次数，默认5
+	MinBackoff  string            `json:"minBackoff,omitempty"`  // 重试的初始等待时间，默认500ms
+	MaxBackoff  string            `json:"maxBackoff,omitempty"`  // 重试的最长等待时间，默认30s
+	Queue       int               `json:"queue,omitempty"`       // 等待发送的最多条数，超出后丢弃最早的日志，默认10000
+	Timeout     string            `json:"timeout,omitempty"`     // 请求超时，默认5s
+	LogLevel    int
+
+	appName    string
+	interval   time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	client     *http.Client
+
+	pending  []httpRecord
+	size     int
+	inflight chan struct{}
+	wg       sync.WaitGroup
+	notify   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+
+	sent    uint64
+	failed  uint64
+	dropped uint64
+}
+
+// httpRecord 已编码的一条日志
+type httpRecord struct {
+	when  time.Time
+	level string
+	line  []byte
+}
+
+// HTTPStats http日志的发送统计
+type HTTPStats struct {
+	Sent     uint64 // 已发送的日志条数
+	Failed   uint64 // 重试后仍失败的日志条数
+	Dropped  uint64 // 队列满丢弃的日志条数
+	Queued   int    // 等待发送的日志条数
+	InFlight int    // 进行中的请求数
+}
+
+func (h *httpLogger) Init(jsonConfig string) error {
+	if len(jsonConfig) == 0 {
+		return nil
+	}
+	h.shutdown()
+	err := json.Unmarshal([]byte(jsonConfig), h)
+	if err != nil {
+		return err
+	}
+	if l, ok := LevelMap[h.Level]; ok {
+		h.LogLevel = l
+	}
+	if err = h.parseConfig(); err != nil {
+		return err
+	}
+	h.inflight = make(chan struct{}, h.MaxInFlight)
+	h.notify = make(chan struct{}, 1)
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go h.run(h.stop, h.done)
+	return nil
+}
+
+func (h *httpLogger) parseConfig() (err error) {
+	if h.URL == "" {
+		return fmt.Errorf("http logger url is empty")
+	}
+	switch h.Format {
+	case "":
+		h.Format = HTTPElasticsearch
+	case HTTPElasticsearch, HTTPLoki:
+	default:
+		return fmt.Errorf("unknown http log format %s", h.Format)
+	}
+	if h.Index == "" {
+		h.Index = httpDefaultIndex
+	}
+	h.appName = h.AppName
+	if h.appName == "" {
+		h.appName = os.Getenv("APPSN")
+	}
+	if h.appName == "" {
+		h.appName = filepath.Base(os.Args[0])
+	}
+	h.interval, h.minBackoff, h.maxBackoff = httpDefaultInterval, connDefaultMinBackoff, connDefaultMaxBackoff
+	timeout := connDefaultTimeout
+	for _, d := range []struct {
+		s string
+		d *time.Duration
+	}{{h.Interval, &h.interval}, {h.MinBackoff, &h.minBackoff}, {h.MaxBackoff, &h.maxBackoff}, {h.Timeout, &timeout}} {
+		if d.s == "" {
+			continue
+		}
+		if *d.d, err = time.ParseDuration(d.s); err != nil {
+			return err
+		}
+	}
+	h.client = &http.Client{Timeout: timeout}
+	if h.BatchSize <= 0 {
+		h.BatchSize = httpDefaultBatchSize
+	}
+	if h.BatchBytes <= 0 {
+		h.BatchBytes = httpDefaultBatchBytes
+	}
+	if h.MaxInFlight <= 0 {
+		h.MaxInFlight = httpDefaultMaxInFlight
+	}
+	if h.MaxRetries <= 0 {
+		h.MaxRetries = httpDefaultMaxRetries
+	}
+	if h.Queue <= 0 {
+		h.Queue = httpDefaultQueue
+	}
+	return nil
+}
+
+func (h *httpLogger) LogWrite(when time.Time, msgText interface{}, level int) error {
+	if level > h.LogLevel {
+		return nil
+	}
+	msg, ok := msgText.(*loginfo)
+	if !ok {
+		return nil
+	}
+	r := httpRecord{when: when, level: msg.Level, line: []byte(msg.jsonLine())}
+	if h.Format == HTTPElasticsearch {
+		// 在json前加上@timestamp和app字段
+		ts, _ := json.Marshal(when.Format(time.RFC3339Nano))
+		app, _ := json.Marshal(h.appName)
+		r.line = append([]byte(`{"@timestamp":`+string(ts)+`,"app":`+string(app)+`,`), r.line[1:]...)
+	}
+
+	h.Lock()
+	if len(h.pending) >= h.Queue {
+		// 丢弃最早的日志
+		h.size -= len(h.pending[0].line)
+		h.pending = h.pending[1:]
+		h.dropped++
+	}
+	h.pending = append(h.pending, r)
+	h.size += len(r.line)
+	full := len(h.pending) >= h.BatchSize || h.size >= h.BatchBytes
+	h.Unlock()
+	if full {
+		select {
+		case h.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run 每隔interval或攒满一批时发送
+func (h *httpLogger) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			h.flush(stop, true)
+			return
+		case <-ticker.C:
+			h.flush(stop, true)
+		case <-h.notify:
+			h.flush(stop, false)
+		}
+	}
+}
+
+// flush 将等待中的日志分批发送，all为false时只发送攒满的批次，进行中的请求达到MaxInFlight时等待
+func (h *httpLogger) flush(stop chan struct{}, all bool) {
+	for {
+		batch := h.next(all)
+		if len(batch) == 0 {
+			return
+		}
+		h.inflight <- struct{}{}
+		h.wg.Add(1)
+		go func() {
+			defer func() {
+				<-h.inflight
+				h.wg.Done()
+			}()
+			h.send(batch, stop)
+		}()
+	}
+}
+
+// next 取出不超过BatchSize条、BatchBytes字节的一批日志
+func (h *httpLogger) next(all bool) []httpRecord {
+	h.Lock()
+	defer h.Unlock()
+	if !all && len(h.pending) < h.BatchSize && h.size < h.BatchBytes {
+		return nil
+	}
+	n, size := 0, 0
+	for n < len(h.pending) && n < h.BatchSize {
+		if n > 0 && size+len(h.pending[n].line) > h.BatchBytes {
+			break
+		}
+		size += len(h.pending[n].line)
+		n++
+	}
+	batch := h.pending[:n:n]
+	h.pending = h.pending[n:]
+	h.size -= size
+	return batch
+}
+
+// send 发送一批日志，网络错误、429和5xx时按指数退避重试，关闭后不再重试
+func (h *httpLogger) send(batch []httpRecord, stop chan struct{}) {
+	body, contentType := h.encode(batch)
+	backoff := h.minBackoff
+	for i := 0; ; i++ {
+		retry, err := h.post(body, contentType)
+		if err == nil {
+			atomic.AddUint64(&h.sent, uint64(len(batch)))
+			return
+		}
+		if retry && i < h.MaxRetries {
+			select {
+			case <-stop:
+			case <-time.After(backoff):
+				if backoff *= 2; backoff > h.maxBackoff {
+					backoff = h.maxBackoff
+				}
+				continue
+			}
+		}
+		fmt.Fprintf(os.Stderr, "http logger send error:%v, %d logs dropped\n", err, len(batch))
+		atomic.AddUint64(&h.failed, uint64(len(batch)))
+		return
+	}
+}
+
+func (h *httpLogger) post(body []byte, contentType string) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return true, fmt.Errorf("%s: %s", resp.Status, data)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("%s: %s", resp.Status, data)
+	}
+	if h.Format == HTTPElasticsearch {
+		// _bulk部分失败时仍返回200，不重试以免重复写入
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		if json.Unmarshal(data, &result) == nil && result.Errors {
+			return false, fmt.Errorf("bulk errors: %s", data)
+		}
+	}
+	return false, nil
+}
+
+// encode 编码请求体，Elasticsearch为_bulk NDJSON，Loki按level分为不同的stream
+func (h *httpLogger) encode(batch []httpRecord) ([]byte, string) {
+	var b bytes.Buffer
+	if h.Format == HTTPElasticsearch {
+		action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": h.Index}})
+		for _, r := range batch {
+			b.Write(action)
+			b.WriteByte('\n')
+			b.Write(r.line)
+			b.WriteByte('\n')
+		}
+		return b.Bytes(), "application/x-ndjson"
+	}
+
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*stream
+	byLevel := make(map[string]*stream)
+	for _, r := range batch {
+		s, ok := byLevel[r.level]
+		if !ok {
+			s = &stream{Stream: map[string]string{"app": h.appName, "level": r.level}}
+			byLevel[r.level] = s
+			streams = append(streams, s)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(r.when.UnixNano(), 10), string(r.line)})
+	}
+	json.NewEncoder(&b).Encode(map[string]interface{}{"streams": streams})
+	return b.Bytes(), "application/json"
+}
+
+// http日志始终使用结构体
+func (h *httpLogger) structured() bool {
+	return true
+}
+
+// Flush 发送等待中的日志，并等待进行中的请求结束
+func (h *httpLogger) Flush() error {
+	stop := h.stop
+	if stop == nil {
+		return nil
+	}
+	failed := atomic.LoadUint64(&h.failed)
+	h.flush(stop, true)
+	// 占满所有并发名额即所有请求都已结束
+	for i := 0; i < cap(h.inflight); i++ {
+		h.inflight <- struct{}{}
+	}
+	for i := 0; i < cap(h.inflight); i++ {
+		<-h.inflight
+	}
+	if n := atomic.LoadUint64(&h.failed) - failed; n > 0 {
+		return fmt.Errorf("http logger: %d logs failed", n)
+	}
+	return nil
+}
+
+// Destroy 发送剩余的日志并等待进行中的请求结束
+func (h *httpLogger) Destroy() {
+	h.shutdown()
+}
+
+func (h *httpLogger) shutdown() {
+	if h.stop == nil {
+		return
+	}
+	close(h.stop)
+	<-h.done
+	h.wg.Wait()
+	h.stop = nil
+}
+
+// Stats 返回发送统计
+func (h *httpLogger) Stats() HTTPStats {
+	h.Lock()
+	defer h.Unlock()
+	return HTTPStats{
+		Sent:     atomic.LoadUint64(&h.sent),
+		Failed:   atomic.LoadUint64(&h.failed),
+		Dropped:  h.dropped,
+		Queued:   len(h.pending),
+		InFlight: len(h.inflight),
+	}
+}
+
+// GetHTTPStats returns the stats of the http adapter of the default logger
+func GetHTTPStats() HTTPStats {
+	h, ok := defaultLogger.adapter(AdapterHTTP).(*httpLogger)
+	if !ok {
+		return HTTPStats{}
+	}
+	return h.Stats()
+}
+
+func init() {
+	Register(AdapterHTTP, func() Logger {
+		return &httpLogger{LogLevel: LevelTrace}
+	})
+}
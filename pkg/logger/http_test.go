@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder 记录收到的请求体，status依次作为响应码，用完后返回200
+type recorder struct {
+	sync.Mutex
+	bodies   [][]byte
+	types    []string
+	status   []int
+	block    chan struct{}
+	inflight int32
+	peak     int32
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	n := atomic.AddInt32(&r.inflight, 1)
+	defer atomic.AddInt32(&r.inflight, -1)
+	for {
+		p := atomic.LoadInt32(&r.peak)
+		if n <= p || atomic.CompareAndSwapInt32(&r.peak, p, n) {
+			break
+		}
+	}
+	if r.block != nil {
+		<-r.block
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	r.Lock()
+	defer r.Unlock()
+	if len(r.status) > 0 {
+		code := r.status[0]
+		r.status = r.status[1:]
+		w.WriteHeader(code)
+		return
+	}
+	r.bodies = append(r.bodies, body)
+	r.types = append(r.types, req.Header.Get("Content-Type"))
+	w.Write([]byte(`{"errors":false}`))
+}
+
+func (r *recorder) received() [][]byte {
+	r.Lock()
+	defer r.Unlock()
+	return append([][]byte(nil), r.bodies...)
+}
+
+func newHTTPLogger(t *testing.T, url, config string) *httpLogger {
+	h := &httpLogger{LogLevel: LevelTrace}
+	if err := h.Init(fmt.Sprintf(`{"url":%q,"appName":"mts",%s}`, url, config)); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func httpSend(h *httpLogger, level int, msgs ...string) {
+	for _, m := range msgs {
+		h.LogWrite(time.Now(), &loginfo{Level: levelPrefix[level], Content: m, Fields: makeFields("sku", 100012043978)}, level)
+	}
+}
+
+func TestHTTPElasticsearch(t *testing.T) {
+	r := &recorder{}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	h := newHTTPLogger(t, s.URL+"/_bulk", `"index":"mts-log","batchSize":2,"interval":"1h"`)
+	httpSend(h, LevelInformational, "1", "2", "3")
+
+	// 攒满两条立即发送，剩下的一条在关闭时发送
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.received()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(r.received()); n != 1 {
+		t.Fatalf("got %d requests before close", n)
+	}
+	h.Destroy()
+	bodies := r.received()
+	if len(bodies) != 2 || r.types[0] != "application/x-ndjson" {
+		t.Fatalf("got %d requests %v", len(bodies), r.types)
+	}
+
+	var lines []map[string]interface{}
+	for _, body := range bodies {
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for sc.Scan() {
+			var m map[string]interface{}
+			if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+				t.Fatalf("%v: %s", err, sc.Bytes())
+			}
+			lines = append(lines, m)
+		}
+	}
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	if idx := lines[0]["index"].(map[string]interface{})["_index"]; idx != "mts-log" {
+		t.Fatalf("index %v", idx)
+	}
+	doc := lines[1]
+	if doc["msg"] != "1" || doc["app"] != "mts" || doc["level"] != "INFO" || doc["sku"] != float64(100012043978) || doc["@timestamp"] == nil {
+		t.Fatalf("unexpected document %v", doc)
+	}
+	if st := h.Stats(); st.Sent != 3 || st.Queued != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestHTTPLoki(t *testing.T) {
+	r := &recorder{}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	h := newHTTPLogger(t, s.URL+"/loki/api/v1/push", `"format":"loki","interval":"10ms"`)
+	httpSend(h, LevelInformational, "1")
+	httpSend(h, LevelError, "2")
+	httpSend(h, LevelInformational, "3")
+	h.Destroy()
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	got := map[string][]string{}
+	for _, body := range r.received() {
+		if err := json.Unmarshal(body, &push); err != nil {
+			t.Fatal(err)
+		}
+		for _, st := range push.Streams {
+			if st.Stream["app"] != "mts" {
+				t.Fatalf("labels %v", st.Stream)
+			}
+			for _, v := range st.Values {
+				got[st.Stream["level"]] = append(got[st.Stream["level"]], v[1])
+			}
+		}
+	}
+	if len(got["INFO"]) != 2 || len(got["EROR"]) != 1 {
+		t.Fatalf("unexpected streams %v", got)
+	}
+}
+
+func TestHTTPRetry(t *testing.T) {
+	r := &recorder{status: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	h := newHTTPLogger(t, s.URL, `"batchSize":1,"minBackoff":"10ms","maxRetries":3`)
+	httpSend(h, LevelInformational, "retried")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Destroy()
+	if st := h.Stats(); st.Sent != 1 || st.Failed != 0 {
+		t.Fatalf("stats %+v", st)
+	}
+
+	// 4xx不重试
+	r.status = []int{http.StatusBadRequest}
+	h = newHTTPLogger(t, s.URL, `"batchSize":1,"minBackoff":"10ms"`)
+	httpSend(h, LevelInformational, "rejected")
+	h.Destroy()
+	if st := h.Stats(); st.Sent != 0 || st.Failed != 1 || len(r.received()) != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestHTTPMaxInFlight(t *testing.T) {
+	r := &recorder{block: make(chan struct{})}
+	s := httptest.NewServer(r)
+	defer s.Close()
+	h := newHTTPLogger(t, s.URL, `"batchSize":1,"maxInFlight":2,"queue":3`)
+	httpSend(h, LevelInformational, "1", "2", "3", "4", "5", "6")
+	time.Sleep(100 * time.Millisecond)
+	st := h.Stats()
+	close(r.block)
+	h.Destroy()
+	// 两个请求进行中，第三批等待发送，队列中最多3条
+	if st.InFlight != 2 || r.peak != 2 || st.Queued > 3 {
+		t.Fatalf("stats %+v peak %d", st, r.peak)
+	}
+	if st = h.Stats(); st.Sent+st.Dropped != 6 {
+		t.Fatalf("stats %+v", st)
+	}
+}
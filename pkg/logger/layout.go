@@ -0,0 +1,210 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// 布局模板中的占位符
+const (
+	tokenText = iota
+	tokenTime
+	tokenLevel
+	tokenCaller
+	tokenFunc
+	tokenFields
+	tokenMsg
+	tokenApp
+)
+
+var layoutTokens = map[string]int{
+	"time":   tokenTime,
+	"level":  tokenLevel,
+	"caller": tokenCaller,
+	"func":   tokenFunc,
+	"fields": tokenFields,
+	"msg":    tokenMsg,
+	"app":    tokenApp,
+}
+
+// DefaultLayout 与未设置layout时的文本格式相同
+const DefaultLayout = "{time} [{level}] [{caller}] {msg} {fields}"
+
+type layoutPart struct {
+	kind int
+	arg  string // 文本内容，或占位符的参数，如{time:15:04:05.000}中的时间格式
+}
+
+// layout 文本日志的布局模板，如 "{time:15:04:05.000} {level} {caller} {fields} {msg}"：
+//
+//	{time} {time:格式}     时间，默认使用TimeFormat
+//	{level}                日志等级
+//	{caller} {caller:long} 调用位置，默认为文件名:行号，long为完整路径
+//	{func} {func:long}     调用的函数名，默认不带包路径
+//	{fields}               结构化字段 k=v
+//	{msg}                  日志内容
+//	{app}                  appName
+//
+// 值为空的占位符连同两侧的[]和一个空格一起省略
+type layout struct {
+	parts  []layoutPart
+	caller bool // 使用了{caller}或{func}
+}
+
+func parseLayout(s string) (*layout, error) {
+	lt := &layout{}
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			lt.parts = append(lt.parts, layoutPart{kind: tokenText, arg: s})
+			break
+		}
+		if i > 0 {
+			lt.parts = append(lt.parts, layoutPart{kind: tokenText, arg: s[:i]})
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("logger: unclosed { in layout %q", s)
+		}
+		name, arg := s[i+1:i+j], ""
+		if k := strings.IndexByte(name, ':'); k >= 0 {
+			name, arg = name[:k], name[k+1:]
+		}
+		kind, ok := layoutTokens[name]
+		if !ok {
+			return nil, fmt.Errorf("logger: unknown layout token {%s}", name)
+		}
+		if (kind == tokenCaller || kind == tokenFunc) && arg != "" && arg != "long" && arg != "short" {
+			return nil, fmt.Errorf("logger: unknown %s format %s", name, arg)
+		}
+		lt.caller = lt.caller || kind == tokenCaller || kind == tokenFunc
+		lt.parts = append(lt.parts, layoutPart{kind: kind, arg: arg})
+		s = s[i+j+1:]
+	}
+	return lt, nil
+}
+
+// format 按模板格式化一条日志
+func (lt *layout) format(msg *loginfo) string {
+	var frame *runtime.Frame
+	b := make([]byte, 0, 128)
+	skip := false // 跳过下一段文本开头的]和空格
+	for _, p := range lt.parts {
+		var v string
+		switch p.kind {
+		case tokenText:
+			text := p.arg
+			if skip {
+				text = strings.TrimPrefix(text, "]")
+				if len(b) == 0 || b[len(b)-1] == ' ' {
+					text = strings.TrimPrefix(text, " ")
+				}
+				skip = false
+			}
+			b = append(b, text...)
+			continue
+		case tokenTime:
+			v = msg.Time
+			if p.arg != "" {
+				v = msg.when.Format(p.arg)
+			}
+		case tokenLevel:
+			v = msg.Level
+		case tokenCaller, tokenFunc:
+			if frame == nil {
+				f := msg.frame()
+				frame = &f
+			}
+			v = formatFrame(*frame, p.kind, p.arg == "long")
+		case tokenFields:
+			if len(msg.Fields) > 0 {
+				v = msg.Fields.Text()
+			}
+		case tokenMsg:
+			v = msg.Content
+		case tokenApp:
+			v = msg.Name
+		}
+		if v != "" {
+			b = append(b, v...)
+			continue
+		}
+		if n := len(b); n > 0 && b[n-1] == '[' {
+			b = b[:n-1]
+		}
+		skip = true
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// formatFrame 返回调用位置或函数名
+func formatFrame(f runtime.Frame, kind int, long bool) string {
+	if f.PC == 0 {
+		return ""
+	}
+	if kind == tokenFunc {
+		if long {
+			return f.Function
+		}
+		return f.Function[strings.LastIndexByte(f.Function, '/')+1:]
+	}
+	if long {
+		return f.File + ":" + strconv.Itoa(f.Line)
+	}
+	return filepath.Base(f.File) + ":" + strconv.Itoa(f.Line)
+}
+
+// SetLayout 为所有支持布局模板的输出(console、file)设置layout，按新配置重新创建这些输出
+func (this *LocalLogger) SetLayout(layout string) error {
+	if _, err := parseLayout(layout); err != nil {
+		return err
+	}
+	this.lock.Lock()
+	var outputs []*nameLogger
+	for _, l := range this.outputs {
+		if _, ok := l.Logger.(layouter); ok {
+			outputs = append(outputs, l)
+		}
+	}
+	this.lock.Unlock()
+	for _, l := range outputs {
+		config := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(l.config), &config); err != nil {
+			return err
+		}
+		if config["layout"] == layout {
+			continue
+		}
+		config["layout"] = layout
+		b, _ := json.Marshal(config)
+		if err := this.SetOutput(l.name, l.typ, string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLayout sets the layout of the default logger, see LocalLogger.SetLayout
+func SetLayout(layout string) error {
+	return defaultLogger.SetLayout(layout)
+}
+
+// layouter 由支持布局模板的适配器实现，返回nil时使用默认格式
+type layouter interface {
+	textLayout() *layout
+}
+
+// colorEnabled 判断是否向out输出颜色，设置了NO_COLOR或out不是终端时不输出
+func colorEnabled(out *os.File) bool {
+	if runtime.GOOS == "windows" || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return term.IsTerminal(int(out.Fd()))
+}
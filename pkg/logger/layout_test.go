@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutFormat(t *testing.T) {
+	when := time.Date(2021, 1, 8, 9, 59, 59, 123456789, time.Local)
+	msg := &loginfo{Time: "09:59:59", Level: "INFO", Content: "开始抢购", Fields: makeFields("worker", 1), Name: "mts", when: when}
+	for layout, want := range map[string]string{
+		"{time:15:04:05.000} {level} {msg} {fields}": "09:59:59.123 INFO 开始抢购 worker=1",
+		"{time} ({caller}) {level}: {msg}":           "09:59:59 () INFO: 开始抢购",
+		"{app}|{caller}|{msg}":                       "mts||开始抢购",
+		DefaultLayout:                                "09:59:59 [INFO] 开始抢购 worker=1",
+	} {
+		lt, err := parseLayout(layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lt.format(msg); got != want {
+			t.Fatalf("%s: got %q, want %q", layout, got, want)
+		}
+	}
+	// 没有字段时省略末尾的空格
+	msg.Fields = nil
+	lt, _ := parseLayout(DefaultLayout)
+	if got := lt.format(msg); got != "09:59:59 [INFO] 开始抢购" {
+		t.Fatalf("got %q", got)
+	}
+
+	for _, bad := range []string{"{time", "{color}", "{caller:full}"} {
+		if _, err := parseLayout(bad); err == nil {
+			t.Fatalf("%s accepted", bad)
+		}
+	}
+}
+
+func TestLayoutCaller(t *testing.T) {
+	defer os.Remove("layout.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	err := log.SetLogger(AdapterFile, `{"filename":"layout.log","level":"DEBG","daily":false,"maxlines":0,"maxsize":0,
+		"layout":"{time:15:04:05.000} {level} [{caller}] [{caller:long}] {func} {func:long} {msg}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info("下单")
+	log.Close()
+	b, err := ioutil.ReadFile("layout.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d{3} INFO \[layout_test\.go:\d+\] \[/\S+/layout_test\.go:\d+\] logger\.TestLayoutCaller \S+/pkg/logger\.TestLayoutCaller 下单\n$`)
+	if !re.Match(b) {
+		t.Fatalf("unexpected output %q", b)
+	}
+}
+
+func TestSetLayout(t *testing.T) {
+	defer os.Remove("layout.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetLogger(AdapterFile, `{"filename":"layout.log","level":"DEBG","daily":false,"maxlines":0,"maxsize":0}`)
+	log.SetLogger(AdapterMemory)
+	log.Info("默认格式")
+	if err := log.SetLayout("{bad}"); err == nil {
+		t.Fatal("bad layout accepted")
+	}
+	if err := log.SetLayout("{level}: {msg}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.SetLayout("{level}: {msg}"); err != nil {
+		t.Fatal(err)
+	}
+	log.With("worker", 2).Warn("自定义格式")
+	if log.Memory() == nil {
+		t.Fatal("memory output removed")
+	}
+	log.Close()
+	b, _ := ioutil.ReadFile("layout.log")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "[INFO] 默认格式") || lines[1] != "WARN: 自定义格式" {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestConsoleColor(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if colorEnabled(w) {
+		t.Fatal("color enabled for a pipe")
+	}
+
+	c := &consoleLogger{Colorful: true}
+	if err := c.Init(`{"output":"stderr","forceColor":true}`); err != nil {
+		t.Fatal(err)
+	}
+	if c.out != os.Stderr || !c.color {
+		t.Fatalf("unexpected console %+v", c)
+	}
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+	if err := c.Init(`{"output":"stdout","forceColor":false}`); err != nil {
+		t.Fatal(err)
+	}
+	if c.out != os.Stdout || c.color {
+		t.Fatalf("unexpected console %+v", c)
+	}
+	if err := c.Init(`{"output":"tty"}`); err == nil {
+		t.Fatal("unknown output accepted")
+	}
+}
@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 常见写法与日志等级的对应关系，LevelMap中的写法同样支持
+var levelAlias = map[string]int{
+	"EMERGENCY": LevelEmergency,
+	"ALERT":     LevelAlert,
+	"CRITICAL":  LevelCritical,
+	"ERROR":     LevelError,
+	"WARNING":   LevelWarning,
+	"NOTICE":    LevelInformational,
+	"DEBUG":     LevelDebug,
+	"TRACE":     LevelTrace,
+}
+
+// ParseLevel 解析日志等级，支持TRAC、DEBUG、warning、3等写法，不区分大小写
+func ParseLevel(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if l, ok := LevelMap[s]; ok {
+		return l, nil
+	}
+	if l, ok := levelAlias[s]; ok {
+		return l, nil
+	}
+	if l, err := strconv.Atoi(s); err == nil && l >= LevelEmergency && l <= LevelTrace {
+		return l, nil
+	}
+	return 0, fmt.Errorf("logger: unknown level %q", s)
+}
+
+// levels 当前生效的日志等级，modules按调用方包名设置等级
+type levels struct {
+	level   int
+	modules map[string]int
+}
+
+// parseLevels 解析 "INFO,chrome=WARN,internal=DEBUG" 格式，不带=的项为默认等级
+func parseLevels(spec string) (*levels, error) {
+	lv := &levels{level: LevelTrace, modules: map[string]int{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i < 0 {
+			l, err := ParseLevel(item)
+			if err != nil {
+				return nil, err
+			}
+			lv.level = l
+			continue
+		}
+		module := strings.TrimSpace(item[:i])
+		l, err := ParseLevel(item[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		if module == "" {
+			return nil, fmt.Errorf("logger: empty module in %q", item)
+		}
+		lv.modules[module] = l
+	}
+	return lv, nil
+}
+
+func (lv *levels) String() string {
+	items := make([]string, 0, len(lv.modules)+1)
+	items = append(items, levelPrefix[lv.level])
+	modules := make([]string, 0, len(lv.modules))
+	for m := range lv.modules {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		items = append(items, m+"="+levelPrefix[lv.modules[m]])
+	}
+	return strings.Join(items, ",")
+}
+
+// callerPackages 缓存调用位置对应的包，pc -> [2]string{完整导入路径, 包名}
+var callerPackages sync.Map
+
+func callerPackage(pc uintptr) [2]string {
+	if p, ok := callerPackages.Load(pc); ok {
+		return p.([2]string)
+	}
+	var p [2]string
+	// 使用CallersFrames以正确处理内联的函数
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	// 如 github.com/oldthreefeng/mts/pkg/chrome.(*x).f.func1
+	if name := frame.Function; name != "" {
+		slash := strings.LastIndex(name, "/") + 1
+		if dot := strings.Index(name[slash:], "."); dot >= 0 {
+			p[0] = name[:slash+dot]
+			p[1] = name[slash : slash+dot]
+		}
+	}
+	callerPackages.Store(pc, p)
+	return p
+}
+
+// SetLevel 设置日志等级，spec如 "INFO" 或 "INFO,chrome=WARN,internal=DEBUG"，
+// 不带=的项为默认等级，其余按调用方包名设置等级，包名可以是最后一级名称或完整导入路径。
+// 可在运行中随时调用，低于等级的日志不会格式化
+func (this *LocalLogger) SetLevel(spec string) error {
+	lv, err := parseLevels(spec)
+	if err != nil {
+		return err
+	}
+	this.levels.Store(lv)
+	return nil
+}
+
+// Level 返回当前的日志等级，格式与SetLevel相同
+func (this *LocalLogger) Level() string {
+	lv, _ := this.levels.Load().(*levels)
+	if lv == nil {
+		return levelPrefix[LevelTrace]
+	}
+	return lv.String()
+}
+
+// enabled 判断level的日志是否需要输出，需由日志方法直接调用，与writeMsg的调用深度相同
+func (this *LocalLogger) enabled(level int) bool {
+	lv, _ := this.levels.Load().(*levels)
+	if lv == nil {
+		return true
+	}
+	if len(lv.modules) == 0 {
+		return level <= lv.level
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(this.callDepth+1, pcs[:]) == 0 {
+		return level <= lv.level
+	}
+	p := callerPackage(pcs[0])
+	if l, ok := lv.modules[p[0]]; ok {
+		return level <= l
+	}
+	if l, ok := lv.modules[p[1]]; ok {
+		return level <= l
+	}
+	return level <= lv.level
+}
+
+// enabledFor 判断名为module的日志来源的level日志是否需要输出，用于转接其他库的日志
+func (this *LocalLogger) enabledFor(level int, module string) bool {
+	lv, _ := this.levels.Load().(*levels)
+	if lv == nil {
+		return true
+	}
+	if l, ok := lv.modules[module]; ok {
+		return level <= l
+	}
+	return level <= lv.level
+}
+
+// SetLevel sets the level of the default logger, see LocalLogger.SetLevel
+func SetLevel(spec string) error {
+	return defaultLogger.SetLevel(spec)
+}
+
+// Level returns the level of the default logger
+func Level() string {
+	return defaultLogger.Level()
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]int{"TRAC": LevelTrace, "debug": LevelDebug, "Warning": LevelWarning, "EROR": LevelError, "3": LevelError} {
+		if l, err := ParseLevel(s); err != nil || l != want {
+			t.Fatalf("%s: %d %v", s, l, err)
+		}
+	}
+	for _, s := range []string{"", "verbose", "8", "info,chrome"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Fatalf("%q accepted", s)
+		}
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	if err := log.SetLevel("info, logger=error"); err != nil {
+		t.Fatal(err)
+	}
+	if l := log.Level(); l != "INFO,logger=EROR" {
+		t.Fatalf("level %s", l)
+	}
+	log.Warn("suppressed by module")
+	log.Error("kept")
+	child := log.With("worker", 1)
+	child.Info("child suppressed")
+
+	// 完整导入路径优先于包名
+	log.SetLevel("EROR,logger=EROR,github.com/oldthreefeng/mts/pkg/logger=DEBUG")
+	child.Debug("child kept")
+	log.Trace("suppressed by path")
+
+	log.SetLevel("WARN")
+	log.Info("suppressed by default")
+	log.Warn("kept by default")
+
+	if err := log.SetLevel("INFO,=WARN"); err == nil {
+		t.Fatal("empty module accepted")
+	}
+	if err := log.SetLevel("LOUD"); err == nil || log.Level() != "WARN" {
+		t.Fatalf("invalid level changed the logger: %v %s", err, log.Level())
+	}
+
+	msgs := gate.received()
+	if len(msgs) != 3 || !strings.HasSuffix(msgs[0], "kept") ||
+		!strings.HasSuffix(msgs[1], "child kept worker=1") || !strings.HasSuffix(msgs[2], "kept by default") {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+}
+
+func TestLevelSkipsFormat(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	log.SetLevel("INFO")
+	formatted := false
+	log.Debug("%v", stringerFunc(func() string { formatted = true; return "x" }))
+	if formatted {
+		t.Fatal("suppressed message was formatted")
+	}
+}
+
+type stringerFunc func() string
+
+func (f stringerFunc) String() string { return f() }
+
+func BenchmarkSuppressed(b *testing.B) {
+	log := newGateLogger(b, nil)
+	defer log.Close()
+	log.SetLevel("INFO,chrome=WARN")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Debug("请求完成", i)
+	}
+}
+
+func TestDefaultLevel(t *testing.T) {
+	defaultLogger.SetLogger("asynctest")
+	defer defaultLogger.DelLogger("asynctest")
+	defer SetLevel("TRAC")
+	SetLevel("DEBUG,logger=WARN")
+	Info("suppressed")
+	Warn("kept")
+	msgs := gate.received()
+	if len(msgs) != 1 || !strings.HasSuffix(msgs[0], "kept") {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+}
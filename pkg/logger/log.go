@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -38,8 +40,12 @@ var LevelMap = map[string]int{
 	"TRAC": LevelTrace,
 }
 
-// 注册实现的适配器， 当前支持控制台，文件和网络输出
-var adapters = make(map[string]Logger)
+// 注册实现的适配器， 当前支持控制台，文件，网络，syslog，http和内存输出。
+// 每次SetLogger时调用工厂函数创建新的实例，LocalLogger之间以及同类型的多个输出互不影响
+var (
+	adaptersLock sync.RWMutex
+	adapters     = make(map[string]AdapterFactory)
+)
 
 // 日志记录等级字段
 var levelPrefix = [LevelTrace + 1]string{
@@ -58,24 +64,61 @@ const (
 	AdapterConsole       = "console"             // 控制台输出配置项
 	AdapterFile          = "file"                // 文件输出配置项
 	AdapterConn          = "conn"                // 网络输出配置项
+	AdapterSyslog        = "syslog"              // syslog输出配置项
+	AdapterHTTP          = "http"                // http批量输出配置项
+	AdapterMemory        = "memory"              // 内存输出配置项
 )
 
 // log provider interface
 type Logger interface {
 	Init(config string) error
 	LogWrite(when time.Time, msg interface{}, level int) error
+	Flush() error // 写出缓冲中的日志，Fatal退出前和LocalLogger.Flush时调用
 	Destroy()
 }
 
-// 日志输出适配器注册，log需要实现Init，LogWrite，Destroy方法
-func Register(name string, log Logger) {
-	if log == nil {
-		panic("logs: Register provide is nil")
+// AdapterFactory 创建未初始化的适配器实例
+type AdapterFactory func() Logger
+
+// Register 注册名为name的适配器类型，factory每次调用需返回新的实例，
+// 返回的实例需要实现Init，LogWrite，Destroy方法。name已注册时返回错误
+func Register(name string, factory AdapterFactory) error {
+	if factory == nil {
+		return fmt.Errorf("logs: Register factory for %s is nil", name)
 	}
+	adaptersLock.Lock()
+	defer adaptersLock.Unlock()
 	if _, ok := adapters[name]; ok {
-		panic("logs: Register called twice for provider " + name)
+		return fmt.Errorf("logs: Register called twice for adapter %s", name)
+	}
+	adapters[name] = factory
+	return nil
+}
+
+// Unregister 取消注册名为name的适配器类型，已创建的输出不受影响
+func Unregister(name string) error {
+	adaptersLock.Lock()
+	defer adaptersLock.Unlock()
+	if _, ok := adapters[name]; !ok {
+		return fmt.Errorf("logs: unknown adapter %s", name)
 	}
-	adapters[name] = log
+	delete(adapters, name)
+	return nil
+}
+
+// newAdapter 创建typ类型的未初始化实例
+func newAdapter(typ string) (Logger, error) {
+	adaptersLock.RLock()
+	factory, ok := adapters[typ]
+	adaptersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown adaptername %s (forgotten Register?)", typ)
+	}
+	l := factory()
+	if l == nil {
+		return nil, fmt.Errorf("logs: factory of adapter %s returned nil", typ)
+	}
+	return l, nil
 }
 
 type loginfo struct {
@@ -84,27 +127,53 @@ type loginfo struct {
 	Path    string
 	Name    string
 	Content string
+	Fields  Fields `json:"Fields,omitempty"`
+
+	when time.Time
+	pc   uintptr // 调用位置，未使用时为0
+}
+
+// frame 返回调用位置
+func (m *loginfo) frame() runtime.Frame {
+	if m.pc == 0 {
+		return runtime.Frame{}
+	}
+	f, _ := runtime.CallersFrames([]uintptr{m.pc}).Next()
+	return f
 }
 
 type nameLogger struct {
 	Logger
-	name   string
+	name   string // 输出名，同一logger内唯一
+	typ    string // 适配器类型
 	config string
+	queue  *asyncQueue // 异步输出时的队列，同步输出时为nil
 }
 
-type LocalLogger struct {
+// loggerCore 保存输出适配器等配置，由LocalLogger和With创建的子logger共享
+type loggerCore struct {
+	dropped    uint64 // 异步队列丢弃的日志数，放在首位保证64位对齐
 	lock       sync.Mutex
 	init       bool
 	outputs    []*nameLogger
 	appName    string
-	callDepth  int
 	timeFormat string
 	usePath    bool
+	async      *asyncOptions
+	levels     atomic.Value // *levels，未设置时不过滤
+	sampler    atomic.Value // *sampler，未设置时不去重和限流
+	callers    int32        // 使用{caller}或{func}的输出数，大于0时记录调用位置
+}
+
+type LocalLogger struct {
+	*loggerCore
+	callDepth int
+	fields    Fields
 }
 
 func NewLogger(depth ...int) *LocalLogger {
 	dep := append(depth, 2)[0]
-	l := new(LocalLogger)
+	l := &LocalLogger{loggerCore: new(loggerCore)}
 	// appName用于记录网络传输时标记的程序发送方，
 	// 通过环境变量APPSN进行设置,默认为NONE,此时无法通过网络日志检索区分不同服务发送方
 	appSn := os.Getenv("APPSN")
@@ -121,16 +190,36 @@ func NewLogger(depth ...int) *LocalLogger {
 //配置文件
 type logConfig struct {
 	TimeFormat string         `json:"TimeFormat"`
+	Level      string         `json:"Level,omitempty"` // 如 INFO,chrome=WARN
+	Async      *AsyncConfig   `json:"Async,omitempty"`
+	Sample     *SampleConfig  `json:"Sample,omitempty"`
 	Console    *consoleLogger `json:"Console,omitempty"`
 	File       *fileLogger    `json:"File,omitempty"`
 	Conn       *connLogger    `json:"Conn,omitempty"`
+	Syslog     *syslogLogger  `json:"Syslog,omitempty"`
+	HTTP       *httpLogger    `json:"HTTP,omitempty"`
+	Outputs    []outputConfig `json:"Outputs,omitempty"`
+}
+
+// outputConfig 命名的输出，Name为空时使用Type
+type outputConfig struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
 }
 
 func init() {
 	defaultLogger = NewLogger(3)
 }
 
+// SetLogger 设置名称和类型均为adapterName的输出，已存在时按新配置重新创建
 func (this *LocalLogger) SetLogger(adapterName string, configs ...string) error {
+	return this.SetOutput(adapterName, adapterName, configs...)
+}
+
+// SetOutput 设置名为name、类型为adapterType的输出，同一类型可以设置多个不同名称的输出，
+// 如错误日志和全部日志分别写入不同的文件
+func (this *LocalLogger) SetOutput(name, adapterType string, configs ...string) error {
 	this.lock.Lock()
 	defer this.lock.Unlock()
 
@@ -144,178 +233,339 @@ func (this *LocalLogger) SetLogger(adapterName string, configs ...string) error
 	var i int
 	var l *nameLogger
 	for i, l = range this.outputs {
-		if l.name == adapterName {
-			if l.config == config {
+		if l.name == name {
+			if l.typ == adapterType && l.config == config {
 				//配置没有变动，不重新设置
-				return fmt.Errorf("you have set same config for this adaptername %s", adapterName)
+				return fmt.Errorf("you have set same config for this output %s", name)
 			}
+			this.closeQueue(l)
 			l.Logger.Destroy()
 			num = i
 			break
 		}
 	}
-	logger, ok := adapters[adapterName]
-	if !ok {
-		return fmt.Errorf("unknown adaptername %s (forgotten Register?)", adapterName)
+	logger, err := newAdapter(adapterType)
+	if err == nil {
+		if err = logger.Init(config); err != nil {
+			fmt.Fprintf(os.Stderr, "logger Init <%s> err:%v, %s output ignore!\n",
+				name, err, name)
+		}
 	}
-
-	err := logger.Init(config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "logger Init <%s> err:%v, %s output ignore!\n",
-			adapterName, err, adapterName)
+		if num >= 0 {
+			// 旧的输出已关闭，移除
+			this.outputs = append(this.outputs[:num], this.outputs[num+1:]...)
+		}
 		return err
 	}
+	nl := &nameLogger{name: name, typ: adapterType, Logger: logger, config: config}
+	if this.async != nil {
+		nl.queue = newAsyncQueue(nl, *this.async, &this.dropped, this.format)
+	}
 	if num >= 0 {
-		this.outputs[i] = &nameLogger{name: adapterName, Logger: logger, config: config}
-		return nil
+		this.outputs[i] = nl
+	} else {
+		this.outputs = append(this.outputs, nl)
 	}
-	this.outputs = append(this.outputs, &nameLogger{name: adapterName, Logger: logger, config: config})
+	this.countCallers()
 	return nil
 }
 
+// countCallers 统计需要调用位置的输出，调用时需持有lock
+func (c *loggerCore) countCallers() {
+	var n int32
+	for _, l := range c.outputs {
+		if lt, ok := l.Logger.(layouter); ok && lt.textLayout() != nil && lt.textLayout().caller {
+			n++
+		}
+	}
+	atomic.StoreInt32(&c.callers, n)
+}
+
+// DelLogger 删除名为adapterName的输出
 func (this *LocalLogger) DelLogger(adapterName string) error {
 	this.lock.Lock()
 	defer this.lock.Unlock()
 	outputs := []*nameLogger{}
 	for _, lg := range this.outputs {
 		if lg.name == adapterName {
+			this.closeQueue(lg)
 			lg.Destroy()
 		} else {
 			outputs = append(outputs, lg)
 		}
 	}
 	if len(outputs) == len(this.outputs) {
-		return fmt.Errorf("logs: unknown adaptername %s (forgotten Register?)", adapterName)
+		return fmt.Errorf("logs: unknown output %s", adapterName)
 	}
 	this.outputs = outputs
+	this.countCallers()
 	return nil
 }
 
+// adapter 返回第一个类型为typ的输出实例，未设置时返回nil
+func (c *loggerCore) adapter(typ string) Logger {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, l := range c.outputs {
+		if l.typ == typ {
+			return l.Logger
+		}
+	}
+	return nil
+}
+
+// OutputConfig 返回名为name的输出的类型和配置，用于暂时删除输出后按原配置恢复
+func (this *LocalLogger) OutputConfig(name string) (typ, config string, ok bool) {
+	if l := this.named(name); l != nil {
+		return l.typ, l.config, true
+	}
+	return "", "", false
+}
+
+// named 返回名为name的输出，未设置时返回nil
+func (c *loggerCore) named(name string) *nameLogger {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, l := range c.outputs {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// With 返回附带kv字段的子logger，kv为key, value, key, value...
+// 子logger与当前logger共享输出适配器，直接调用其方法输出日志
+func (this *LocalLogger) With(kv ...interface{}) *LocalLogger {
+	return &LocalLogger{
+		loggerCore: this.loggerCore,
+		callDepth:  2,
+		fields:     this.fields.with(makeFields(kv...)),
+	}
+}
+
 // 设置日志起始路径
 func (this *LocalLogger) SetLogPath(bPath bool) {
 	this.usePath = bPath
 }
 func (this *LocalLogger) writeToLoggers(when time.Time, msg *loginfo, level int) {
+	// 在lock外放入异步队列，block策略下队列满时不阻塞其他输出和SetOutput等调用
+	var dbuf, lbuf [4]*nameLogger
+	var qbuf [4]*asyncQueue
+	direct, queued, queues := dbuf[:0], lbuf[:0], qbuf[:0]
+	this.lock.Lock()
 	for _, l := range this.outputs {
-		if l.name == AdapterConn {
-			//网络日志，使用json格式发送,此处使用结构体，用于类似ElasticSearch功能检索
-			err := l.LogWrite(when, msg, level)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", l.name, err)
-			}
-			continue
+		if l.queue != nil {
+			queued = append(queued, l)
+			queues = append(queues, l.queue)
+		} else {
+			direct = append(direct, l)
+		}
+	}
+	this.lock.Unlock()
+	r := logRecord{when: when, msg: msg, level: level}
+	for i, q := range queues {
+		if !q.push(r) && this.requeue(queued[i], r) {
+			direct = append(direct, queued[i])
+		}
+	}
+	msgStr := ""
+	for _, l := range direct {
+		err := l.LogWrite(when, this.message(l, when, msg, &msgStr), level)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", l.name, err)
 		}
+	}
+}
 
+// requeue 放入时队列被SetAsync关闭，按l当前的队列重新放入；l已恢复同步输出时返回true，已删除时丢弃
+func (this *LocalLogger) requeue(l *nameLogger, r logRecord) bool {
+	for {
+		this.lock.Lock()
+		q, ok := l.queue, false
+		for _, o := range this.outputs {
+			ok = ok || o == l
+		}
+		this.lock.Unlock()
+		if !ok {
+			return false
+		}
+		if q == nil {
+			return true
+		}
+		if q.push(r) {
+			return false
+		}
+	}
+}
+
+// message 返回写入适配器l的内容，text缓存格式化后的文本行供多个适配器复用
+func (c *loggerCore) message(l *nameLogger, when time.Time, msg *loginfo, text *string) interface{} {
+	if s, ok := l.Logger.(structured); ok && s.structured() {
+		//网络日志及json格式，使用结构体，用于类似ElasticSearch功能检索
+		return msg
+	}
+	if lt, ok := l.Logger.(layouter); ok && lt.textLayout() != nil {
+		return lt.textLayout().format(msg)
+	}
+	if *text == "" {
 		strLevel := " [" + msg.Level + "] "
 		strPath := "[" + msg.Path + "] "
-		if !this.usePath {
+		if !c.usePath {
 			strPath = ""
 		}
-
-		msgStr := when.Format(this.timeFormat) + strLevel + strPath + msg.Content
-		err := l.LogWrite(when, msgStr, level)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "unable to WriteMsg to adapter:%v,error:%v\n", l.name, err)
+		*text = when.Format(c.timeFormat) + strLevel + strPath + msg.Content
+		if len(msg.Fields) > 0 {
+			*text += " " + msg.Fields.Text()
 		}
 	}
+	return *text
+}
+
+// format 异步队列在写入goroutine中格式化日志
+func (c *loggerCore) format(l *nameLogger, r logRecord) interface{} {
+	text := ""
+	return c.message(l, r.when, r.msg.(*loginfo), &text)
 }
 
 func (this *LocalLogger) writeMsg(logLevel int, msg string, v ...interface{}) error {
 	if !this.init {
 		this.SetLogger(AdapterConsole)
 	}
-	msgSt := new(loginfo)
-	src := ""
 	if len(v) > 0 {
 		msg = fmt.Sprintf(msg, v...)
 	}
-	when := time.Now()
-	//
+	s, _ := this.sampler.Load().(*sampler)
+	var pc uintptr
+	if s != nil || this.usePath || atomic.LoadInt32(&this.callers) > 0 {
+		var pcs [1]uintptr
+		runtime.Callers(this.callDepth+1, pcs[:])
+		pc = pcs[0]
+	}
+	if s != nil && !s.allow(this, pc, logLevel, msg) {
+		return nil
+	}
+	this.output(logLevel, time.Now(), pc, msg)
+	return nil
+}
+
+// output 构造日志并写入所有适配器，pc为调用位置
+func (this *LocalLogger) output(logLevel int, when time.Time, pc uintptr, msg string) {
+	msgSt := new(loginfo)
+	msgSt.Level = levelPrefix[logLevel]
+	msgSt.pc = pc
 	if this.usePath {
-		_, file, lineno, ok := runtime.Caller(this.callDepth)
-		var strim = "/"
-		if ok {
-			codeArr := strings.Split(file, strim)
-			code := codeArr[len(codeArr)-1]
-			src = strings.Replace(
-				fmt.Sprintf("%s:%d", code, lineno), "%2e", ".", -1)
+		f := msgSt.frame()
+		if f.File != "" {
+			msgSt.Path = strings.Replace(
+				fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line), "%2e", ".", -1)
 		}
 	}
-	//
-	msgSt.Level = levelPrefix[logLevel]
-	msgSt.Path = src
-	msgSt.Content = msg
+	msgSt.Content = Redact(msg)
+	msgSt.Fields = this.fields
 	msgSt.Name = this.appName
+	msgSt.when = when
 	msgSt.Time = when.Format(this.timeFormat)
 	this.writeToLoggers(when, msgSt, logLevel)
-
-	return nil
 }
 
+// Fatal 输出EMER日志，执行退出hook并写出所有适配器的日志后退出，最多等待SetExitTimeout设置的时间
 func (this *LocalLogger) Fatal(format string, args ...interface{}) {
 	this.Emer("###Exec Panic:"+format, args...)
-	os.Exit(1)
+	shutdown(this)
+	osExit(1)
 }
 
+// Panic 输出EMER日志，写出所有适配器的日志后panic
 func (this *LocalLogger) Panic(format string, args ...interface{}) {
 	this.Emer("###Exec Panic:"+format, args...)
+	this.Flush()
 	panic(fmt.Sprintf(format, args...))
 }
 
 // Emer Log EMERGENCY level message.
-func (this *LocalLogger) Emer(format string, v ...interface{}) {
-	this.writeMsg(LevelEmergency, format, v...)
+func (this *LocalLogger) Emer(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelEmergency) {
+		return
+	}
+	this.writeMsg(LevelEmergency, formatLog(f, v...))
 }
 
 // Alert Log ALERT level message.
-func (this *LocalLogger) Alert(format string, v ...interface{}) {
-	this.writeMsg(LevelAlert, format, v...)
+func (this *LocalLogger) Alert(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelAlert) {
+		return
+	}
+	this.writeMsg(LevelAlert, formatLog(f, v...))
 }
 
 // Crit Log CRITICAL level message.
-func (this *LocalLogger) Crit(format string, v ...interface{}) {
-	this.writeMsg(LevelCritical, format, v...)
+func (this *LocalLogger) Crit(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelCritical) {
+		return
+	}
+	this.writeMsg(LevelCritical, formatLog(f, v...))
 }
 
 // Error Log ERROR level message.
-func (this *LocalLogger) Error(format string, v ...interface{}) {
-	this.writeMsg(LevelError, format, v...)
+func (this *LocalLogger) Error(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelError) {
+		return
+	}
+	this.writeMsg(LevelError, formatLog(f, v...))
 }
 
 // Warn Log WARNING level message.
-func (this *LocalLogger) Warn(format string, v ...interface{}) {
-	this.writeMsg(LevelWarning, format, v...)
+func (this *LocalLogger) Warn(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelWarning) {
+		return
+	}
+	this.writeMsg(LevelWarning, formatLog(f, v...))
 }
 
 // Info Log INFO level message.
-func (this *LocalLogger) Info(format string, v ...interface{}) {
-	this.writeMsg(LevelInformational, format, v...)
+func (this *LocalLogger) Info(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelInformational) {
+		return
+	}
+	this.writeMsg(LevelInformational, formatLog(f, v...))
 }
 
 // Debug Log DEBUG level message.
-func (this *LocalLogger) Debug(format string, v ...interface{}) {
-	this.writeMsg(LevelDebug, format, v...)
+func (this *LocalLogger) Debug(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelDebug) {
+		return
+	}
+	this.writeMsg(LevelDebug, formatLog(f, v...))
 }
 
 // Trace Log TRAC level message.
-func (this *LocalLogger) Trace(format string, v ...interface{}) {
-	this.writeMsg(LevelTrace, format, v...)
+func (this *LocalLogger) Trace(f interface{}, v ...interface{}) {
+	if !this.enabled(LevelTrace) {
+		return
+	}
+	this.writeMsg(LevelTrace, formatLog(f, v...))
 }
 
-func (this *LocalLogger) Close() {
-
+// Close 写完异步队列后关闭所有适配器，超时未写完返回ErrFlushTimeout
+func (this *LocalLogger) Close() error {
+	this.flushSampler()
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var err error
 	for _, l := range this.outputs {
+		if e := this.closeQueue(l); e != nil {
+			err = e
+		}
 		l.Destroy()
 	}
 	this.outputs = nil
-
+	return err
 }
 
 func (this *LocalLogger) Reset() {
-	for _, l := range this.outputs {
-		l.Destroy()
-	}
-	this.outputs = nil
+	this.Close()
 }
 
 func (this *LocalLogger) SetCallDepth(depth int) {
@@ -347,31 +597,41 @@ func SetLogger(param ...string) error {
 	c := param[0]
 	conf := new(logConfig)
 	err := json.Unmarshal([]byte(c), conf)
-	if err != nil { //不是json，就认为是配置文件，如果都不是，打印日志，然后退出
+	if err != nil { //不是json，就认为是配置文件，如果都不是，返回错误
 		// Open the configuration file
 		fd, err := os.Open(c)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not open %s for configure: %s\n", c, err)
-			os.Exit(1)
-			return err
+			return fmt.Errorf("could not open %s for configure: %v", c, err)
 		}
+		defer fd.Close()
 
 		contents, err := ioutil.ReadAll(fd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not read %s: %s\n", c, err)
-			os.Exit(1)
-			return err
+			return fmt.Errorf("could not read %s: %v", c, err)
 		}
 		err = json.Unmarshal(contents, conf)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not Unmarshal %s: %s\n", contents, err)
-			os.Exit(1)
-			return err
+			return fmt.Errorf("could not unmarshal %s: %v", c, err)
 		}
 	}
 	if conf.TimeFormat != "" {
 		defaultLogger.timeFormat = conf.TimeFormat
 	}
+	if conf.Level != "" {
+		if err = defaultLogger.SetLevel(conf.Level); err != nil {
+			fmt.Fprintf(os.Stderr, "logger Level config err:%v, level ignore\n", err)
+		}
+	}
+	if conf.Sample != nil {
+		if err = defaultLogger.SetSampling(conf.Sample); err != nil {
+			fmt.Fprintf(os.Stderr, "logger Sample config err:%v, sampling ignore\n", err)
+		}
+	}
+	if conf.Async != nil {
+		if err = defaultLogger.SetAsync(conf.Async); err != nil {
+			fmt.Fprintf(os.Stderr, "logger Async config err:%v, use sync output\n", err)
+		}
+	}
 	if conf.Console != nil {
 		console, _ := json.Marshal(conf.Console)
 		defaultLogger.SetLogger(AdapterConsole, string(console))
@@ -384,6 +644,27 @@ func SetLogger(param ...string) error {
 		conn, _ := json.Marshal(conf.Conn)
 		defaultLogger.SetLogger(AdapterConn, string(conn))
 	}
+	if conf.Syslog != nil {
+		syslog, _ := json.Marshal(conf.Syslog)
+		defaultLogger.SetLogger(AdapterSyslog, string(syslog))
+	}
+	if conf.HTTP != nil {
+		h, _ := json.Marshal(conf.HTTP)
+		defaultLogger.SetLogger(AdapterHTTP, string(h))
+	}
+	for _, o := range conf.Outputs {
+		name := o.Name
+		if name == "" {
+			name = o.Type
+		}
+		config := "{}"
+		if len(o.Config) > 0 {
+			config = string(o.Config)
+		}
+		if err = defaultLogger.SetOutput(name, o.Type, config); err != nil {
+			fmt.Fprintf(os.Stderr, "logger output %s config err:%v, output ignore\n", name, err)
+		}
+	}
 	return nil
 }
 
@@ -399,42 +680,47 @@ func Fatal(f interface{}, v ...interface{}) {
 
 // Emer logs a message at emergency level.
 func Emer(f interface{}, v ...interface{}) {
-	defaultLogger.Emer(formatLog(f, v...))
+	defaultLogger.Emer(f, v...)
 }
 
 // Alert logs a message at alert level.
 func Alert(f interface{}, v ...interface{}) {
-	defaultLogger.Alert(formatLog(f, v...))
+	defaultLogger.Alert(f, v...)
 }
 
 // Crit logs a message at critical level.
 func Crit(f interface{}, v ...interface{}) {
-	defaultLogger.Crit(formatLog(f, v...))
+	defaultLogger.Crit(f, v...)
 }
 
 // Error logs a message at error level.
 func Error(f interface{}, v ...interface{}) {
-	defaultLogger.Error(formatLog(f, v...))
+	defaultLogger.Error(f, v...)
 }
 
 // Warn logs a message at warning level.
 func Warn(f interface{}, v ...interface{}) {
-	defaultLogger.Warn(formatLog(f, v...))
+	defaultLogger.Warn(f, v...)
 }
 
 // Info logs a message at info level.
 func Info(f interface{}, v ...interface{}) {
-	defaultLogger.Info(formatLog(f, v...))
+	defaultLogger.Info(f, v...)
 }
 
 // Notice logs a message at debug level.
 func Debug(f interface{}, v ...interface{}) {
-	defaultLogger.Debug(formatLog(f, v...))
+	defaultLogger.Debug(f, v...)
 }
 
 // Trace logs a message at trace level.
 func Trace(f interface{}, v ...interface{}) {
-	defaultLogger.Trace(formatLog(f, v...))
+	defaultLogger.Trace(f, v...)
+}
+
+// With returns a child of the default logger with the key value fields.
+func With(kv ...interface{}) *LocalLogger {
+	return defaultLogger.With(kv...)
 }
 
 func formatLog(f interface{}, v ...interface{}) string {
@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const memoryDefaultSize = 1000
+
+// Record 内存中保存的一条日志
+type Record struct {
+	Time   time.Time `json:"time"`
+	Level  string    `json:"level"`
+	Path   string    `json:"path,omitempty"`
+	Msg    string    `json:"msg"`
+	Fields Fields    `json:"fields,omitempty"`
+}
+
+// Field 返回字段key的值
+func (r Record) Field(key string) (interface{}, bool) {
+	for _, f := range r.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// MemoryLogger 在环形缓冲区中保存最近的日志，供控制接口和界面展示，也用于测试中检查输出
+type MemoryLogger struct {
+	sync.Mutex
+	Size     int    `json:"size"`  // 保存的最近日志条数，默认1000
+	Level    string `json:"level"` // 日志输出等级
+	LogLevel int
+
+	ring []Record
+	next int
+	full bool
+	subs map[chan Record]struct{}
+}
+
+func (m *MemoryLogger) Init(jsonConfig string) error {
+	if len(jsonConfig) == 0 {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	if err := json.Unmarshal([]byte(jsonConfig), m); err != nil {
+		return err
+	}
+	if l, ok := LevelMap[m.Level]; ok {
+		m.LogLevel = l
+	}
+	if m.Size <= 0 {
+		m.Size = memoryDefaultSize
+	}
+	m.ring, m.next, m.full = make([]Record, m.Size), 0, false
+	return nil
+}
+
+func (m *MemoryLogger) LogWrite(when time.Time, msgText interface{}, level int) error {
+	if level > m.LogLevel {
+		return nil
+	}
+	msg, ok := msgText.(*loginfo)
+	if !ok {
+		return nil
+	}
+	r := Record{Time: when, Level: msg.Level, Path: msg.Path, Msg: msg.Content, Fields: msg.Fields}
+	m.Lock()
+	defer m.Unlock()
+	if len(m.ring) > 0 {
+		m.ring[m.next] = r
+		if m.next++; m.next == len(m.ring) {
+			m.next, m.full = 0, true
+		}
+	}
+	for ch := range m.subs {
+		// 订阅者处理不过来时丢弃，不阻塞日志输出
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+	return nil
+}
+
+// Records 按时间顺序返回最近的n条日志，n<=0时返回全部
+func (m *MemoryLogger) Records(n int) []Record {
+	m.Lock()
+	defer m.Unlock()
+	var out []Record
+	if m.full {
+		out = append(out, m.ring[m.next:]...)
+	}
+	out = append(out, m.ring[:m.next]...)
+	if n > 0 && len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out
+}
+
+// Messages 按时间顺序返回所有日志的内容
+func (m *MemoryLogger) Messages() []string {
+	rs := m.Records(0)
+	msgs := make([]string, len(rs))
+	for i, r := range rs {
+		msgs[i] = r.Msg
+	}
+	return msgs
+}
+
+// Reset 清空缓冲区
+func (m *MemoryLogger) Reset() {
+	m.Lock()
+	defer m.Unlock()
+	for i := range m.ring {
+		m.ring[i] = Record{}
+	}
+	m.next, m.full = 0, false
+}
+
+// Subscribe 返回接收新日志的channel，buf为channel的缓冲大小，channel满时丢弃日志；
+// 调用cancel取消订阅并关闭channel
+func (m *MemoryLogger) Subscribe(buf int) (<-chan Record, func()) {
+	ch := make(chan Record, buf)
+	m.Lock()
+	if m.subs == nil {
+		m.subs = make(map[chan Record]struct{})
+	}
+	m.subs[ch] = struct{}{}
+	m.Unlock()
+	return ch, func() {
+		m.Lock()
+		defer m.Unlock()
+		if _, ok := m.subs[ch]; ok {
+			delete(m.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// 内存日志保存结构体，字段可直接用于检索
+func (m *MemoryLogger) structured() bool {
+	return true
+}
+
+func (m *MemoryLogger) Flush() error {
+	return nil
+}
+
+// Destroy 关闭所有订阅的channel
+func (m *MemoryLogger) Destroy() {
+	m.Lock()
+	defer m.Unlock()
+	for ch := range m.subs {
+		delete(m.subs, ch)
+		close(ch)
+	}
+}
+
+// Memory 返回logger的内存输出，未设置memory适配器时返回nil
+func (this *LocalLogger) Memory() *MemoryLogger {
+	m, _ := this.adapter(AdapterMemory).(*MemoryLogger)
+	return m
+}
+
+// MemoryOutput 返回名为name的内存输出，不存在或类型不是memory时返回nil
+func (this *LocalLogger) MemoryOutput(name string) *MemoryLogger {
+	if l := this.named(name); l != nil {
+		m, _ := l.Logger.(*MemoryLogger)
+		return m
+	}
+	return nil
+}
+
+// Memory returns the memory adapter of the default logger
+func Memory() *MemoryLogger {
+	return defaultLogger.Memory()
+}
+
+// Capture 返回只输出到内存的logger，用于测试中检查输出的日志
+func Capture() (*LocalLogger, *MemoryLogger) {
+	l := NewLogger()
+	l.DelLogger(AdapterConsole)
+	l.SetLogger(AdapterMemory, `{"size":10000}`)
+	return l, l.Memory()
+}
+
+func init() {
+	Register(AdapterMemory, func() Logger {
+		return &MemoryLogger{LogLevel: LevelTrace}
+	})
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryRing(t *testing.T) {
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	log.SetLogger(AdapterMemory, `{"size":3,"level":"INFO"}`)
+	defer log.Close()
+	mem := log.Memory()
+	log.Debug("suppressed")
+	for i := 1; i <= 5; i++ {
+		log.With("worker", i).Info("attempt %d", i)
+	}
+	rs := mem.Records(0)
+	if fmt.Sprint(mem.Messages()) != "[attempt 3 attempt 4 attempt 5]" || rs[0].Level != "INFO" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+	if w, ok := rs[2].Field("worker"); !ok || w != 5 {
+		t.Fatalf("worker %v", w)
+	}
+	if rs = mem.Records(1); len(rs) != 1 || rs[0].Msg != "attempt 5" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+	mem.Reset()
+	if len(mem.Records(0)) != 0 {
+		t.Fatal("reset failed")
+	}
+}
+
+func TestMemorySubscribe(t *testing.T) {
+	log, mem := Capture()
+	defer log.Close()
+	ch, cancel := mem.Subscribe(1)
+	log.Warn("first")
+	log.Warn("dropped when the subscriber is slow")
+	select {
+	case r := <-ch:
+		if r.Msg != "first" || r.Level != "WARN" {
+			t.Fatalf("unexpected record %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no record received")
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed")
+	}
+	cancel()
+	if len(mem.Messages()) != 2 {
+		t.Fatalf("unexpected messages %q", mem.Messages())
+	}
+
+	// Destroy关闭剩余的订阅
+	ch, cancel = mem.Subscribe(0)
+	log.DelLogger(AdapterMemory)
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed")
+	}
+	cancel()
+}
+
+func TestIndependentAdapters(t *testing.T) {
+	a, memA := Capture()
+	defer a.Close()
+	b, memB := Capture()
+	defer b.Close()
+	if memA == memB {
+		t.Fatal("loggers share the memory adapter")
+	}
+	a.Info("a")
+	b.Info("b")
+	if fmt.Sprint(memA.Messages(), memB.Messages()) != "[a] [b]" {
+		t.Fatalf("unexpected messages %q %q", memA.Messages(), memB.Messages())
+	}
+	if NewLogger().Memory() != nil {
+		t.Fatal("memory adapter not set")
+	}
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTwoFileOutputs(t *testing.T) {
+	defer os.Remove("errors.log")
+	defer os.Remove("all.log")
+	log := NewLogger()
+	log.DelLogger(AdapterConsole)
+	if err := log.SetOutput("errors", AdapterFile, `{"filename":"errors.log","level":"EROR","daily":false,"maxlines":0,"maxsize":0}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.SetOutput("all", AdapterFile, `{"filename":"all.log","level":"DEBG","daily":false,"maxlines":0,"maxsize":0}`); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("下单成功")
+	log.Error("下单失败")
+	log.Close()
+
+	for name, want := range map[string]int{"errors.log": 1, "all.log": 2} {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(b), "\n"); n != want {
+			t.Fatalf("%s: got %d lines, want %d\n%s", name, n, want, b)
+		}
+	}
+}
+
+type countLogger struct {
+	inits int
+}
+
+func (c *countLogger) Init(string) error {
+	c.inits++
+	return nil
+}
+func (c *countLogger) LogWrite(when time.Time, msg interface{}, level int) error { return nil }
+func (c *countLogger) Flush() error                                              { return nil }
+func (c *countLogger) Destroy()                                                  {}
+
+func TestRegisterFactory(t *testing.T) {
+	if err := Register("counttest", func() Logger { return &countLogger{} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("counttest", func() Logger { return &countLogger{} }); err == nil {
+		t.Fatal("duplicate register accepted")
+	}
+	if err := Register("niltest", nil); err == nil {
+		t.Fatal("nil factory accepted")
+	}
+
+	a, b := NewLogger(), NewLogger()
+	a.SetLogger("counttest")
+	b.SetOutput("x", "counttest")
+	b.SetOutput("y", "counttest")
+	ca, cx := a.adapter("counttest").(*countLogger), b.adapter("counttest").(*countLogger)
+	if ca == cx || ca.inits != 1 || cx.inits != 1 {
+		t.Fatalf("instances not isolated: %p %p %d %d", ca, cx, ca.inits, cx.inits)
+	}
+
+	if err := Unregister("counttest"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("counttest"); err == nil {
+		t.Fatal("unknown adapter unregistered")
+	}
+	// 已创建的输出不受影响，新的输出无法创建
+	if b.adapter("counttest") != cx {
+		t.Fatal("existing output removed")
+	}
+	if err := b.SetOutput("z", "counttest"); err == nil {
+		t.Fatal("unregistered adapter created")
+	}
+	if err := b.DelLogger("y"); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	b.Close()
+}
+
+func TestConfigOutputs(t *testing.T) {
+	err := SetLogger(`{"Outputs":[{"name":"recent","type":"memory","config":{"size":5}},{"type":"memory","config":{"level":"EROR"}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer defaultLogger.DelLogger("recent")
+	defer defaultLogger.DelLogger(AdapterMemory)
+	var mems []*MemoryLogger
+	defaultLogger.lock.Lock()
+	for _, l := range defaultLogger.outputs {
+		if m, ok := l.Logger.(*MemoryLogger); ok {
+			mems = append(mems, m)
+		}
+	}
+	defaultLogger.lock.Unlock()
+	if len(mems) != 2 {
+		t.Fatalf("got %d memory outputs", len(mems))
+	}
+	Warn("warn")
+	Error("error")
+	if got := fmt.Sprint(mems[0].Messages(), mems[1].Messages()); got != "[warn error] [error]" {
+		t.Fatalf("unexpected messages %s", got)
+	}
+}
+
+func TestOutputConfig(t *testing.T) {
+	log := NewLogger()
+	defer log.Close()
+	log.SetOutput("tui", AdapterMemory, `{"size":2}`)
+	typ, config, ok := log.OutputConfig("tui")
+	if !ok || typ != AdapterMemory || config != `{"size":2}` {
+		t.Fatalf("unexpected output %v %q %q", ok, typ, config)
+	}
+	if _, _, ok = log.OutputConfig("missing"); ok {
+		t.Fatal("missing output found")
+	}
+	// 删除后可以按原配置恢复
+	typ, config, _ = log.OutputConfig(AdapterConsole)
+	log.DelLogger(AdapterConsole)
+	if err := log.SetOutput(AdapterConsole, typ, config); err != nil {
+		t.Fatal(err)
+	}
+	mem := log.MemoryOutput("tui")
+	log.Info("a")
+	if mem == nil || fmt.Sprint(mem.Messages()) != "[a]" {
+		t.Fatalf("unexpected memory output %v", mem)
+	}
+	if log.MemoryOutput(AdapterConsole) != nil {
+		t.Fatal("console returned as memory output")
+	}
+}
@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SampleConfig 去重和限流配置，按调用位置统计，避免重试循环刷屏
+type SampleConfig struct {
+	Window string         `json:"window"` // 统计窗口，默认1s
+	Burst  int            `json:"burst"`  // WARN以下每个调用位置在窗口内最多输出的条数，默认10，小于0不限流
+	Levels map[string]int `json:"levels"` // 按等级设置burst，如{"EROR":100,"DEBG":2}，WARN及以上默认不限流
+	Dedup  *bool          `json:"dedup"`  // 合并同一位置连续重复的消息，默认开启
+}
+
+type sampleOptions struct {
+	window time.Duration
+	burst  [LevelTrace + 1]int
+	dedup  bool
+}
+
+func (c *SampleConfig) options() (sampleOptions, error) {
+	o := sampleOptions{window: time.Second, dedup: true}
+	if c.Window != "" {
+		d, err := time.ParseDuration(c.Window)
+		if err != nil {
+			return o, err
+		}
+		if d <= 0 {
+			return o, fmt.Errorf("logger: invalid sample window %s", c.Window)
+		}
+		o.window = d
+	}
+	burst := 10
+	if c.Burst != 0 {
+		burst = c.Burst
+	}
+	for i := range o.burst {
+		o.burst[i] = burst
+		// 错误和警告默认不限流，避免抢购失败的原因被丢弃
+		if i <= LevelWarning {
+			o.burst[i] = -1
+		}
+	}
+	for name, b := range c.Levels {
+		l, err := ParseLevel(name)
+		if err != nil {
+			return o, err
+		}
+		o.burst[l] = b
+	}
+	if c.Dedup != nil {
+		o.dedup = *c.Dedup
+	}
+	return o, nil
+}
+
+// sampler 记录每个调用位置在当前窗口内的输出情况
+type sampler struct {
+	opts  sampleOptions
+	mu    sync.Mutex
+	sites map[uintptr]*sampleSite
+}
+
+type sampleSite struct {
+	start    time.Time
+	n        int // 窗口内已输出的条数
+	last     string
+	seen     bool
+	repeated int // 与last相同而未输出的条数
+	dropped  int // 超过burst未输出的条数
+	log      *LocalLogger
+	level    int
+	timer    *time.Timer
+}
+
+func newSampler(opts sampleOptions) *sampler {
+	return &sampler{opts: opts, sites: make(map[uintptr]*sampleSite)}
+}
+
+// allow 判断pc处的msg是否输出，被合并或限流时返回false，窗口结束后输出汇总
+func (s *sampler) allow(l *LocalLogger, pc uintptr, level int, msg string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	site, ok := s.sites[pc]
+	now := time.Now()
+	if !ok {
+		site = &sampleSite{start: now}
+		s.sites[pc] = site
+	}
+	if now.Sub(site.start) >= s.opts.window {
+		s.report(pc, site)
+		site.start, site.n = now, 0
+	}
+	if s.opts.dedup && site.seen && msg == site.last && sameFields(l.fields, site.log.fields) {
+		site.repeated++
+		site.log, site.level = l, level
+		s.schedule(pc, site)
+		return false
+	}
+	if site.repeated > 0 {
+		// 消息变化时先输出上一条的重复次数
+		s.report(pc, site)
+	}
+	site.last, site.seen, site.log = msg, true, l
+	if b := s.opts.burst[level]; b >= 0 && site.n >= b {
+		site.dropped++
+		site.level = level
+		s.schedule(pc, site)
+		return false
+	}
+	site.n++
+	return true
+}
+
+// schedule 在窗口结束时输出汇总，调用时需持有mu
+func (s *sampler) schedule(pc uintptr, site *sampleSite) {
+	if site.timer != nil {
+		return
+	}
+	site.timer = time.AfterFunc(s.opts.window-time.Since(site.start), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		site.timer = nil
+		s.report(pc, site)
+	})
+}
+
+// report 输出site中被合并和限流的条数，调用时需持有mu
+func (s *sampler) report(pc uintptr, site *sampleSite) {
+	if site.timer != nil {
+		site.timer.Stop()
+		site.timer = nil
+	}
+	if site.repeated == 0 && site.dropped == 0 {
+		return
+	}
+	var parts []string
+	if site.repeated > 0 {
+		parts = append(parts, fmt.Sprintf("重复%d次", site.repeated))
+	}
+	if site.dropped > 0 {
+		parts = append(parts, fmt.Sprintf("限流丢弃%d条", site.dropped))
+	}
+	msg := site.last + " (" + strings.Join(parts, "，") + ")"
+	site.repeated, site.dropped = 0, 0
+	site.log.output(site.level, time.Now(), pc, msg)
+}
+
+// flush 立即输出所有位置的汇总
+func (s *sampler) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pc, site := range s.sites {
+		s.report(pc, site)
+	}
+}
+
+// sameFields 判断是否为同一个logger的字段，不同worker的日志不合并
+func sameFields(a, b Fields) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// SetSampling 开启去重和限流，nil时关闭并输出未输出的汇总
+func (this *LocalLogger) SetSampling(c *SampleConfig) error {
+	var s *sampler
+	if c != nil {
+		opts, err := c.options()
+		if err != nil {
+			return err
+		}
+		s = newSampler(opts)
+	}
+	if old, _ := this.sampler.Load().(*sampler); old != nil {
+		old.flush()
+	}
+	this.sampler.Store(s)
+	return nil
+}
+
+// flushSampler 输出去重和限流的汇总
+func (c *loggerCore) flushSampler() {
+	if s, _ := c.sampler.Load().(*sampler); s != nil {
+		s.flush()
+	}
+}
+
+// SetSampling sets the sampling of the default logger, see LocalLogger.SetSampling
+func SetSampling(c *SampleConfig) error {
+	return defaultLogger.SetSampling(c)
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleDedup(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	if err := log.SetSampling(&SampleConfig{Window: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		log.Warn("抢购链接获取失败.....正在重试")
+	}
+	log.Warn("抢购连接获取成功....")
+	// 不同worker的相同消息不合并
+	for i := 1; i <= 2; i++ {
+		log.With("worker", i).Info("正在访问抢购连接......")
+	}
+	log.Flush()
+
+	msgs := gate.received()
+	// 汇总在Flush时输出
+	want := []string{
+		"抢购链接获取失败.....正在重试",
+		"抢购连接获取成功....",
+		"正在访问抢购连接...... worker=1",
+		"正在访问抢购连接...... worker=2",
+		"抢购链接获取失败.....正在重试 (重复99次)",
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+	for i, m := range msgs {
+		if !strings.HasSuffix(m, want[i]) {
+			t.Fatalf("got %q, want %q", m, want[i])
+		}
+	}
+}
+
+func TestSampleBurst(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	no := false
+	log.SetSampling(&SampleConfig{Window: "1h", Levels: map[string]int{"INFO": 3, "EROR": -1}, Dedup: &no})
+	for i := 0; i < 10; i++ {
+		log.Info("attempt %d", i)
+	}
+	for i := 0; i < 20; i++ {
+		log.Error("error")
+	}
+	log.Flush()
+
+	msgs := gate.received()
+	if len(msgs) != 24 || !strings.HasSuffix(msgs[2], "attempt 2") || !strings.HasSuffix(msgs[23], "attempt 9 (限流丢弃7条)") {
+		t.Fatalf("unexpected output %d %q", len(msgs), msgs)
+	}
+}
+
+func TestSampleDefaultLevels(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	log.SetSampling(&SampleConfig{Window: "1h"})
+	for i := 0; i < 20; i++ {
+		log.Info("attempt %d", i)
+	}
+	for i := 0; i < 20; i++ {
+		log.Error("订单提交失败 %d", i)
+	}
+	log.Flush()
+
+	// INFO默认每个位置10条，EROR不限流
+	msgs := gate.received()
+	if len(msgs) != 31 || !strings.HasSuffix(msgs[29], "订单提交失败 19") || !strings.HasSuffix(msgs[30], "attempt 19 (限流丢弃10条)") {
+		t.Fatalf("unexpected output %d %q", len(msgs), msgs)
+	}
+}
+
+func TestSampleWindow(t *testing.T) {
+	log := newGateLogger(t, nil)
+	defer log.Close()
+	log.SetLogPath(true)
+	log.SetSampling(&SampleConfig{Window: "20ms"})
+	for i := 0; i < 5; i++ {
+		log.Info("等待重试")
+	}
+	// 窗口结束后自动输出汇总，位置为原调用位置
+	time.Sleep(100 * time.Millisecond)
+	msgs := gate.received()
+	if len(msgs) != 2 || !strings.HasSuffix(msgs[1], "等待重试 (重复4次)") || !strings.Contains(msgs[1], "[sample_test.go:") {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+	// 不同调用位置分别统计
+	log.Info("等待重试")
+	log.Info("等待重试")
+	log.SetSampling(nil)
+	if msgs = gate.received(); len(msgs) != 4 {
+		t.Fatalf("unexpected output %q", msgs)
+	}
+}
+
+func TestSampleConfig(t *testing.T) {
+	for _, c := range []*SampleConfig{{Window: "x"}, {Window: "-1s"}, {Levels: map[string]int{"LOUD": 1}}} {
+		if _, err := c.options(); err == nil {
+			t.Fatalf("%+v accepted", c)
+		}
+	}
+}
+
+func BenchmarkSampleDedup(b *testing.B) {
+	log := newGateLogger(b, nil)
+	defer log.Close()
+	log.SetSampling(&SampleConfig{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Info("抢购链接获取失败.....正在重试")
+	}
+}
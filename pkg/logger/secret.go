@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SecretMask 替换日志中的敏感信息
+const SecretMask = "******"
+
+// 短于该长度的值不注册，避免误伤订单号、时间戳等普通日志内容；
+// 6位支付密码等短值由passwordPattern按key屏蔽
+const minSecretLen = 8
+
+var secrets = struct {
+	sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}{values: map[string]bool{}}
+
+// cookie请求头、Set-Cookie以及cookie日志中的值
+var cookiePattern = regexp.MustCompile(`(?i)((?:^|[\s"'])(?:set-)?cookie"?\s*[:=]\s*"?)[^\r\n"']*`)
+
+// password=xxx、"payPwd":"xxx"等形式的密码值
+var passwordPattern = regexp.MustCompile(`(?i)((?:^|[\s"'?&{,])(?:pay)?(?:password|passwd|pwd)"?\s*[:=]\s*"?)[^\s&"',}]+`)
+
+// RegisterSecret 注册敏感值，之后所有适配器输出的日志中该值都会被替换为SecretMask
+func RegisterSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	changed := false
+	for _, v := range values {
+		if len(v) < minSecretLen || secrets.values[v] {
+			continue
+		}
+		secrets.values[v] = true
+		changed = true
+	}
+	if changed {
+		secrets.replacer = nil
+	}
+}
+
+// UnregisterSecret 取消注册敏感值
+func UnregisterSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range values {
+		delete(secrets.values, v)
+	}
+	secrets.replacer = nil
+}
+
+// Redact 屏蔽s中已注册的敏感值以及cookie值
+func Redact(s string) string {
+	secrets.RLock()
+	r := secrets.replacer
+	n := len(secrets.values)
+	secrets.RUnlock()
+	if r == nil && n > 0 {
+		secrets.Lock()
+		if secrets.replacer == nil {
+			pairs := make([]string, 0, len(secrets.values)*2)
+			for v := range secrets.values {
+				pairs = append(pairs, v, SecretMask)
+			}
+			secrets.replacer = strings.NewReplacer(pairs...)
+		}
+		r = secrets.replacer
+		secrets.Unlock()
+	}
+	if r != nil {
+		s = r.Replace(s)
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "cookie") {
+		s = cookiePattern.ReplaceAllString(s, "${1}"+SecretMask)
+	}
+	if strings.Contains(lower, "pwd") || strings.Contains(lower, "passw") {
+		s = passwordPattern.ReplaceAllString(s, "${1}"+SecretMask)
+	}
+	return s
+}
+
+// Secret is a string which never shows its value when printed or marshaled
+type Secret string
+
+// NewSecret return s as a Secret and register it
+func NewSecret(s string) Secret {
+	RegisterSecret(s)
+	return Secret(s)
+}
+
+// String implements fmt.Stringer
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+// GoString implements fmt.GoStringer
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Reveal return the real value
+func (s Secret) Reveal() string {
+	return string(s)
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterSecret("123456", "ab", "session-token")
+	defer UnregisterSecret("session-token")
+	cases := map[string]string{
+		"password=123456&num=1":        "password=******&num=1",
+		`{"payPwd":"123456","num":1}`:  `{"payPwd":"******","num":1}`,
+		"订单编号: 9123456780":             "订单编号: 9123456780",
+		"thor=session-token":           "thor=******",
+		"cookie: thor=abc; pin=x":      "cookie: ******",
+		"Set-Cookie: pt_key=abc":       "Set-Cookie: ******",
+		"ab cookie设置失败":                "ab cookie设置失败",
+		`{"Cookie":"a=1","skuId":"1"}`: `{"Cookie":"******","skuId":"1"}`,
+	}
+	for in, want := range cases {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s := NewSecret("pay-654321")
+	defer UnregisterSecret("pay-654321")
+	b, _ := json.Marshal(map[string]Secret{"pwd": s})
+	for _, got := range []string{fmt.Sprint(s), fmt.Sprintf("%#v", s), string(b)} {
+		if strings.Contains(got, "654321") {
+			t.Fatalf("secret leaked: %s", got)
+		}
+	}
+	if s.Reveal() != "pay-654321" {
+		t.Fatal("reveal")
+	}
+}
+
+func TestSecretNotLogged(t *testing.T) {
+	RegisterSecret("my-pay-pwd")
+	defer UnregisterSecret("my-pay-pwd")
+	defer os.Remove("secret.log")
+	log := NewLogger()
+	log.SetLogger(AdapterFile, `{"filename":"secret.log"}`)
+	log.Info("订单参数：password=my-pay-pwd&num=1")
+	log.Info("cookie: thor=session-value")
+	log.Close()
+	b, err := ioutil.ReadFile("secret.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "my-pay-pwd") || strings.Contains(string(b), "session-value") {
+		t.Fatalf("secret leaked: %s", b)
+	}
+}
@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslog格式
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// 日志等级对应的syslog severity，INFO对应informational，DEBG和TRAC对应debug
+var syslogSeverity = [LevelTrace + 1]int{
+	LevelEmergency:     0,
+	LevelAlert:         1,
+	LevelCritical:      2,
+	LevelError:         3,
+	LevelWarning:       4,
+	LevelInformational: 6,
+	LevelDebug:         7,
+	LevelTrace:         7,
+}
+
+var syslogFacility = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// 本地syslog的unix socket
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+type syslogLogger struct {
+	sync.Mutex
+	conn     net.Conn
+	Net      string `json:"net"`      // udp、tcp、unix、unixgram，为空时连接本地syslog
+	Addr     string `json:"addr"`     // 如127.0.0.1:514或/dev/log
+	Level    string `json:"level"`    // 日志输出等级
+	Facility string `json:"facility"` // 默认user
+	Format   string `json:"format"`   // rfc5424或rfc3164，默认rfc5424
+	AppName  string `json:"appName"`  // 默认使用环境变量APPSN，未设置时为程序名
+	LogLevel int
+
+	facility int
+	hostname string
+	appName  string
+	stream   bool
+}
+
+func (s *syslogLogger) Init(jsonConfig string) error {
+	if len(jsonConfig) == 0 {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.close()
+	err := json.Unmarshal([]byte(jsonConfig), s)
+	if err != nil {
+		return err
+	}
+	if l, ok := LevelMap[s.Level]; ok {
+		s.LogLevel = l
+	}
+	f, ok := syslogFacility[strings.ToLower(s.Facility)]
+	if s.Facility == "" {
+		f, ok = syslogFacility["user"], true
+	}
+	if !ok {
+		return fmt.Errorf("unknown syslog facility %s", s.Facility)
+	}
+	s.facility = f
+	switch s.Format {
+	case "":
+		s.Format = SyslogRFC5424
+	case SyslogRFC5424, SyslogRFC3164:
+	default:
+		return fmt.Errorf("unknown syslog format %s", s.Format)
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	s.appName = s.AppName
+	if s.appName == "" {
+		s.appName = os.Getenv("APPSN")
+	}
+	if s.appName == "" {
+		s.appName = filepath.Base(os.Args[0])
+	}
+	return s.connect()
+}
+
+func (s *syslogLogger) connect() error {
+	s.close()
+	if s.Net != "" {
+		conn, err := net.DialTimeout(s.Net, s.Addr, connDefaultTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn, s.stream = conn, s.Net != "udp" && s.Net != "udp4" && s.Net != "udp6" && s.Net != "unixgram"
+		return nil
+	}
+	addrs := syslogSockets
+	if s.Addr != "" {
+		addrs = []string{s.Addr}
+	}
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, addr); err == nil {
+				s.conn, s.stream = conn, network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("unix syslog delivery error")
+}
+
+func (s *syslogLogger) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *syslogLogger) LogWrite(when time.Time, msgText interface{}, level int) error {
+	if level > s.LogLevel {
+		return nil
+	}
+	msg, ok := msgText.(*loginfo)
+	if !ok {
+		return nil
+	}
+	var line string
+	if s.Format == SyslogRFC3164 {
+		line = s.rfc3164(when, msg, level)
+	} else {
+		line = s.rfc5424(when, msg, level)
+	}
+	if s.stream {
+		if s.Format == SyslogRFC5424 {
+			// RFC 6587 octet counting
+			line = strconv.Itoa(len(line)) + " " + line
+		} else {
+			line += "\n"
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.conn != nil {
+		if _, err := s.conn.Write([]byte(line)); err == nil {
+			return nil
+		}
+	}
+	// 连接断开后重连一次
+	if err := s.connect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+func (s *syslogLogger) priority(level int) int {
+	return s.facility*8 + syslogSeverity[level]
+}
+
+// rfc5424 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [mts@32473 path="" k="v"] MSG
+func (s *syslogLogger) rfc5424(when time.Time, msg *loginfo, level int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - ", s.priority(level),
+		when.Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.appName, os.Getpid())
+	if msg.Path == "" && len(msg.Fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[mts@32473")
+		if msg.Path != "" {
+			writeSDParam(&b, "path", msg.Path)
+		}
+		for _, f := range msg.Fields {
+			writeSDParam(&b, f.Key, fmt.Sprint(fieldValue(f.Value)))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(" ")
+	b.WriteString(msg.Content)
+	return b.String()
+}
+
+// writeSDParam 写入 name="value"，name只保留允许的字符，value转义"\]
+func writeSDParam(b *strings.Builder, name, value string) {
+	n := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(n) > 32 {
+		n = n[:32]
+	}
+	if n == "" {
+		return
+	}
+	b.WriteString(" " + n + `="`)
+	b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value))
+	b.WriteString(`"`)
+}
+
+// rfc3164 <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG k=v
+func (s *syslogLogger) rfc3164(when time.Time, msg *loginfo, level int) string {
+	line := fmt.Sprintf("<%d>%s %s %s[%d]: %s", s.priority(level), when.Format(time.Stamp),
+		s.hostname, s.appName, os.Getpid(), msg.Content)
+	if len(msg.Fields) > 0 {
+		line += " " + msg.Fields.Text()
+	}
+	return line
+}
+
+// syslog始终使用结构体，字段写入structured data
+func (s *syslogLogger) structured() bool {
+	return true
+}
+
+// Flush 每条日志直接发送，没有缓冲
+func (s *syslogLogger) Flush() error {
+	return nil
+}
+
+func (s *syslogLogger) Destroy() {
+	s.Lock()
+	defer s.Unlock()
+	s.close()
+}
+
+func init() {
+	Register(AdapterSyslog, func() Logger {
+		return &syslogLogger{LogLevel: LevelTrace}
+	})
+}
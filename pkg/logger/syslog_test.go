@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) - (-|\[.*\]) (.*)$`)
+
+func testSyslogMsg() *loginfo {
+	return &loginfo{Level: "WARN", Path: "jd.go:438", Content: "提交订单失败", Fields: makeFields("worker", 1, "note", `a"b]`)}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	os.Setenv("APPSN", "mts-test")
+	defer os.Unsetenv("APPSN")
+
+	s := &syslogLogger{LogLevel: LevelTrace}
+	if err = s.Init(fmt.Sprintf(`{"net":"udp","addr":%q,"facility":"local0"}`, pc.LocalAddr())); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Destroy()
+	s.LogWrite(time.Now(), testSyslogMsg(), LevelWarning)
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rfc5424.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("not rfc5424: %s", buf[:n])
+	}
+	// local0(16)*8 + warning(4)
+	if m[1] != "132" || m[4] != "mts-test" || m[5] != strconv.Itoa(os.Getpid()) || m[7] != "提交订单失败" {
+		t.Fatalf("unexpected header %q", m)
+	}
+	if m[6] != `[mts@32473 path="jd.go:438" worker="1" note="a\"b\]"]` {
+		t.Fatalf("unexpected structured data %s", m[6])
+	}
+	if _, err = time.Parse(time.RFC3339Nano, m[2]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan string, 4)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(conn)
+		// 第一条为octet counting，之后为换行分隔
+		size, _ := r.ReadString(' ')
+		n, _ := strconv.Atoi(strings.TrimSpace(size))
+		b := make([]byte, n)
+		if _, err := r.Read(b); err == nil {
+			lines <- string(b)
+		}
+		line, _ := r.ReadString('\n')
+		lines <- line
+	}()
+
+	s := &syslogLogger{LogLevel: LevelTrace}
+	if err = s.Init(fmt.Sprintf(`{"net":"tcp","addr":%q,"appName":"mts"}`, l.Addr())); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Destroy()
+	s.LogWrite(time.Now(), &loginfo{Content: "info"}, LevelInformational)
+	s.Format = SyslogRFC3164
+	s.LogWrite(time.Now(), testSyslogMsg(), LevelTrace)
+
+	// user(1)*8 + informational(6)
+	if got := <-lines; !strings.HasPrefix(got, "<14>1 ") || !strings.HasSuffix(got, " mts "+strconv.Itoa(os.Getpid())+" - - info") {
+		t.Fatalf("unexpected rfc5424 %q", got)
+	}
+	// user(1)*8 + debug(7)
+	want := regexp.MustCompile(`^<15>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d \S+ mts\[\d+\]: 提交订单失败 worker=1 note="a\\"b]"\n$`)
+	if got := <-lines; !want.MatchString(got) {
+		t.Fatalf("unexpected rfc3164 %q", got)
+	}
+}
+
+func TestSyslogUnix(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "syslog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	// 未指定net时按本地syslog连接
+	s := &syslogLogger{LogLevel: LevelTrace}
+	if err = s.Init(fmt.Sprintf(`{"addr":%q,"level":"INFO"}`, path)); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Destroy()
+	s.LogWrite(time.Now(), &loginfo{Content: "debug"}, LevelDebug)
+	s.LogWrite(time.Now(), &loginfo{Content: "error"}, LevelError)
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := rfc5424.FindStringSubmatch(string(buf[:n])); m == nil || m[1] != "11" || m[7] != "error" {
+		t.Fatalf("unexpected message %s", buf[:n])
+	}
+}
+
+func TestSyslogConfig(t *testing.T) {
+	s := &syslogLogger{}
+	if err := s.Init(`{"net":"udp","addr":"127.0.0.1:514","facility":"local9"}`); err == nil {
+		t.Fatal("unknown facility accepted")
+	}
+	if err := s.Init(`{"net":"udp","addr":"127.0.0.1:514","format":"rfc1"}`); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
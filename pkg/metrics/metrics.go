@@ -0,0 +1,347 @@
+// Package metrics is a small implementation of prometheus counters, gauges
+// and histograms with the text exposition format. mts only exposes a handful
+// of metrics, client_golang would pull protobuf and procfs into a go1.15 module.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric is a metric family which can be exposed by a Registry
+type Metric interface {
+	Name() string
+	Write(w *bufio.Writer)
+}
+
+// Registry hold metric families in register order
+type Registry struct {
+	mu      sync.RWMutex
+	metrics []Metric
+	names   map[string]bool
+}
+
+// NewRegistry return an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// DefaultRegistry is used by the package level functions
+var DefaultRegistry = NewRegistry()
+
+// Register add ms to r, return error on duplicate name
+func (r *Registry) Register(ms ...Metric) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range ms {
+		if r.names[m.Name()] {
+			return fmt.Errorf("metrics: duplicate metric %s", m.Name())
+		}
+		r.names[m.Name()] = true
+		r.metrics = append(r.metrics, m)
+	}
+	return nil
+}
+
+// MustRegister is like Register but panic on error
+func (r *Registry) MustRegister(ms ...Metric) {
+	if err := r.Register(ms...); err != nil {
+		panic(err)
+	}
+}
+
+// WriteTo write all metrics in text exposition format
+func (r *Registry) WriteTo(w *bufio.Writer) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, m := range r.metrics {
+		m.Write(w)
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	r.WriteTo(bw)
+	bw.Flush()
+}
+
+// MustRegister add ms to the DefaultRegistry
+func MustRegister(ms ...Metric) {
+	DefaultRegistry.MustRegister(ms...)
+}
+
+// Handler return the http handler of the DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + d.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help) + "\n")
+	w.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+}
+
+// labelPairs 生成{k="v",...}，extra为追加的标签如le
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(d.labels)+len(extra)/2)
+	for i, l := range d.labels {
+		pairs = append(pairs, l+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// value is a float64 updated atomically
+type value struct {
+	bits uint64
+}
+
+func (v *value) Add(f float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + f)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// series 按标签值保存子指标
+type series struct {
+	mu       sync.RWMutex
+	children map[string]interface{}
+	values   map[string][]string
+}
+
+func (s *series) get(d *desc, values []string, create func() interface{}) interface{} {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s.mu.RLock()
+	c, ok := s.children[key]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok = s.children[key]; ok {
+		return c
+	}
+	if s.children == nil {
+		s.children = map[string]interface{}{}
+		s.values = map[string][]string{}
+	}
+	c = create()
+	s.children[key] = c
+	s.values[key] = append([]string(nil), values...)
+	return c
+}
+
+// each 按标签值排序遍历，保证输出稳定
+func (s *series) each(fn func(values []string, c interface{})) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.children))
+	for k := range s.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(s.values[k], s.children[k])
+	}
+}
+
+// Counter only goes up
+type Counter struct {
+	value
+}
+
+// Inc add 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	desc
+	series
+}
+
+// NewCounterVec create a counter family, no labels means a single counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{desc: desc{name: name, help: help, typ: "counter", labels: labels}}
+}
+
+// WithLabelValues return the counter of the label values
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.get(&v.desc, values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+// Write implements Metric
+func (v *CounterVec) Write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, c interface{}) {
+		w.WriteString(v.name + v.labelPairs(values) + " " + formatFloat(c.(*Counter).Get()) + "\n")
+	})
+}
+
+// Gauge can go up and down
+type Gauge struct {
+	value
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	desc
+	series
+}
+
+// NewGaugeVec create a gauge family, no labels means a single gauge
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{desc: desc{name: name, help: help, typ: "gauge", labels: labels}}
+}
+
+// WithLabelValues return the gauge of the label values
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.get(&v.desc, values, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+// Write implements Metric
+func (v *GaugeVec) Write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, c interface{}) {
+		w.WriteString(v.name + v.labelPairs(values) + " " + formatFloat(c.(*Gauge).Get()) + "\n")
+	})
+}
+
+// GaugeFunc is a gauge whose value is computed on every scrape
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc create a gauge which calls fn on scrape
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn}
+}
+
+// Write implements Metric
+func (g *GaugeFunc) Write(w *bufio.Writer) {
+	g.writeHeader(w)
+	w.WriteString(g.name + " " + formatFloat(g.fn()) + "\n")
+}
+
+// DefBuckets are the default histogram buckets in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram count observations into buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// Observe add one observation
+func (h *Histogram) Observe(f float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if f <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += f
+	h.count++
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	desc
+	series
+	buckets []float64
+}
+
+// NewHistogramVec create a histogram family, nil buckets means DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{desc: desc{name: name, help: help, typ: "histogram", labels: labels}, buckets: buckets}
+}
+
+// WithLabelValues return the histogram of the label values
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.get(&v.desc, values, func() interface{} {
+		return &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+	}).(*Histogram)
+}
+
+// Write implements Metric
+func (v *HistogramVec) Write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, c interface{}) {
+		h := c.(*Histogram)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, b := range h.buckets {
+			w.WriteString(v.name + "_bucket" + v.labelPairs(values, "le", formatFloat(b)) + " " + strconv.FormatUint(h.counts[i], 10) + "\n")
+		}
+		w.WriteString(v.name + "_bucket" + v.labelPairs(values, "le", "+Inf") + " " + strconv.FormatUint(h.count, 10) + "\n")
+		w.WriteString(v.name + "_sum" + v.labelPairs(values) + " " + formatFloat(h.sum) + "\n")
+		w.WriteString(v.name + "_count" + v.labelPairs(values) + " " + strconv.FormatUint(h.count, 10) + "\n")
+	})
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, err := ts.Client().Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	attempts := NewCounterVec("mts_attempts_total", "attempts", "stage", "result")
+	latency := NewHistogramVec("mts_request_duration_seconds", "latency", []float64{0.1, 0.5}, "endpoint")
+	offset := NewGaugeVec("mts_clock_offset_seconds", "offset")
+	r.MustRegister(attempts, latency, offset, NewGaugeFunc("mts_up", "up", func() float64 { return 1 }))
+
+	attempts.WithLabelValues("submit", "fail").Inc()
+	attempts.WithLabelValues("submit", "fail").Inc()
+	attempts.WithLabelValues("init", `o"k`).Add(3)
+	latency.WithLabelValues("submitOrder.action").Observe(0.05)
+	latency.WithLabelValues("submitOrder.action").Observe(0.3)
+	offset.WithLabelValues().Set(-0.25)
+
+	out := scrape(t, r)
+	for _, want := range []string{
+		"# TYPE mts_attempts_total counter\n",
+		`mts_attempts_total{stage="init",result="o\"k"} 3` + "\n",
+		`mts_attempts_total{stage="submit",result="fail"} 2` + "\n",
+		`mts_request_duration_seconds_bucket{endpoint="submitOrder.action",le="0.1"} 1` + "\n",
+		`mts_request_duration_seconds_bucket{endpoint="submitOrder.action",le="0.5"} 2` + "\n",
+		`mts_request_duration_seconds_bucket{endpoint="submitOrder.action",le="+Inf"} 2` + "\n",
+		`mts_request_duration_seconds_sum{endpoint="submitOrder.action"} 0.35` + "\n",
+		`mts_request_duration_seconds_count{endpoint="submitOrder.action"} 2` + "\n",
+		"mts_clock_offset_seconds -0.25\n",
+		"mts_up 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounterVec("a_total", "a"))
+	if err := r.Register(NewGaugeVec("a_total", "a")); err == nil {
+		t.Fatal("expected duplicate error")
+	}
+}
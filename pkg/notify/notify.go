@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oldthreefeng/mts/pkg/logger"
+)
+
+// 通知类型
+const (
+	KindSuccess      = "success"       // 抢购成功
+	KindFailure      = "failure"       // 最终失败退出
+	KindLoginExpired = "login_expired" // 登陆失效
+	KindStarting     = "starting"      // 即将开始
+)
+
+// 通知方式
+const (
+	TypeWebhook = "webhook"
+	TypeSMTP    = "smtp"
+	TypeRobot   = "robot"
+)
+
+// Message is the content of a notification
+type Message struct {
+	Kind     string            `json:"kind"`
+	Title    string            `json:"title"`
+	Content  string            `json:"content"`
+	Time     time.Time         `json:"time"`
+	Platform string            `json:"platform,omitempty"`
+	SkuId    string            `json:"skuId,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// Text return the plain text of m, used by smtp and robot
+func (m Message) Text() string {
+	s := m.Title + "\n" + m.Content
+	if m.Platform != "" {
+		s += "\n平台: " + m.Platform
+	}
+	if m.SkuId != "" {
+		s += "\n商品: " + m.SkuId
+	}
+	for k, v := range m.Fields {
+		s += "\n" + k + ": " + v
+	}
+	return s + "\n时间: " + m.Time.Format("2006-01-02 15:04:05.000")
+}
+
+// Notifier send a message to one destination
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// Config is one notifier entry of the config file
+type Config struct {
+	Type    string        `yaml:"type" json:"type"`
+	Events  []string      `yaml:"events,omitempty" json:"events,omitempty"`   // 为空时全部通知
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 单次发送超时，默认10s
+
+	// webhook和robot
+	URL     string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Secret  string            `yaml:"secret,omitempty" json:"secret,omitempty"` // 钉钉机器人加签密钥
+
+	// smtp
+	Addr     string   `yaml:"addr,omitempty" json:"addr,omitempty"`
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string   `yaml:"password,omitempty" json:"password,omitempty"`
+	From     string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To       []string `yaml:"to,omitempty" json:"to,omitempty"`
+	TLS      bool     `yaml:"tls,omitempty" json:"tls,omitempty"` // 465端口等直接使用tls连接
+}
+
+// New create a notifier from c
+func New(c Config) (Notifier, error) {
+	switch c.Type {
+	case TypeWebhook:
+		return NewWebhook(c.URL, c.Headers)
+	case TypeSMTP:
+		return NewSMTP(c.Addr, c.Username, c.Password, c.From, c.To, c.TLS)
+	case TypeRobot:
+		return NewRobot(c.URL, c.Secret)
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", c.Type)
+}
+
+type entry struct {
+	Notifier
+	name    string
+	kinds   map[string]bool
+	timeout time.Duration
+}
+
+func (e *entry) accept(kind string) bool {
+	return len(e.kinds) == 0 || e.kinds[kind]
+}
+
+// Dispatcher send messages to several notifiers in background
+type Dispatcher struct {
+	entries []*entry
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher create notifiers from configs
+func NewDispatcher(configs []Config) (*Dispatcher, error) {
+	d := &Dispatcher{}
+	for i, c := range configs {
+		n, err := New(c)
+		if err != nil {
+			return nil, fmt.Errorf("notify[%d]: %v", i, err)
+		}
+		d.Add(fmt.Sprintf("%s[%d]", c.Type, i), n, c.Timeout, c.Events...)
+	}
+	return d, nil
+}
+
+// Add register n for the given kinds, all kinds if empty
+func (d *Dispatcher) Add(name string, n Notifier, timeout time.Duration, kinds ...string) {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	e := &entry{Notifier: n, name: name, timeout: timeout, kinds: map[string]bool{}}
+	for _, k := range kinds {
+		e.kinds[k] = true
+	}
+	d.entries = append(d.entries, e)
+}
+
+// Len return the number of notifiers
+func (d *Dispatcher) Len() int {
+	return len(d.entries)
+}
+
+// Send deliver m to every notifier which accepts its kind, without blocking
+func (d *Dispatcher) Send(m Message) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	for _, e := range d.entries {
+		if !e.accept(m.Kind) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(e *entry) {
+			defer d.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+			defer cancel()
+			if err := e.Notify(ctx, m); err != nil {
+				logger.Warn("通知发送失败", e.name, err)
+			}
+		}(e)
+	}
+}
+
+// Wait block until all sending finished or timeout, return false on timeout
+func (d *Dispatcher) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
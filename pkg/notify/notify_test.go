@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordNotifier struct {
+	mu    sync.Mutex
+	kinds []string
+}
+
+func (r *recordNotifier) Notify(ctx context.Context, m Message) error {
+	r.mu.Lock()
+	r.kinds = append(r.kinds, m.Kind)
+	r.mu.Unlock()
+	return nil
+}
+
+func TestDispatcherKinds(t *testing.T) {
+	all, success := &recordNotifier{}, &recordNotifier{}
+	d := &Dispatcher{}
+	d.Add("all", all, 0)
+	d.Add("success", success, 0, KindSuccess)
+
+	d.Send(Message{Kind: KindStarting})
+	d.Send(Message{Kind: KindSuccess})
+	if !d.Wait(time.Second) {
+		t.Fatal("dispatcher wait timeout")
+	}
+	if len(all.kinds) != 2 {
+		t.Fatal("expected 2 messages, got", all.kinds)
+	}
+	if len(success.kinds) != 1 || success.kinds[0] != KindSuccess {
+		t.Fatal("expected only success, got", success.kinds)
+	}
+}
+
+func TestNewDispatcher(t *testing.T) {
+	if _, err := NewDispatcher([]Config{{Type: "pager"}}); err == nil {
+		t.Fatal("expected unknown type error")
+	}
+	d, err := NewDispatcher([]Config{
+		{Type: TypeWebhook, URL: "http://127.0.0.1/hook"},
+		{Type: TypeRobot, URL: "http://127.0.0.1/robot"},
+		{Type: TypeSMTP, Addr: "127.0.0.1:25", To: []string{"a@example.com"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 3 {
+		t.Fatal("expected 3 notifiers, got", d.Len())
+	}
+}
+
+func parseQuery(q string) (map[string]string, error) {
+	v, err := url.ParseQuery(q)
+	m := map[string]string{}
+	for k := range v {
+		m[k] = v.Get(k)
+	}
+	return m, err
+}
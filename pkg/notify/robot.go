@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Robot send text messages to a WeCom or DingTalk group robot webhook
+type Robot struct {
+	URL    string
+	Secret string // 钉钉加签密钥，企业微信不需要
+	Client *http.Client
+}
+
+// NewRobot return a group robot notifier
+func NewRobot(url string, secret string) (*Robot, error) {
+	if url == "" {
+		return nil, errors.New("robot must have url")
+	}
+	return &Robot{URL: url, Secret: secret, Client: http.DefaultClient}, nil
+}
+
+// Notify implements Notifier
+func (r *Robot) Notify(ctx context.Context, m Message) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": m.Text()},
+	})
+	u, err := r.signedURL(time.Now())
+	if err != nil {
+		return err
+	}
+	b, err := postJSON(ctx, r.Client, u, nil, body)
+	if err != nil {
+		return err
+	}
+	// 企业微信和钉钉都以errcode表示发送结果
+	res := gjson.ParseBytes(b)
+	if code := res.Get("errcode").Int(); code != 0 {
+		return fmt.Errorf("robot errcode: %d, errmsg: %s", code, res.Get("errmsg").String())
+	}
+	return nil
+}
+
+// signedURL 钉钉加签：timestamp+"\n"+secret 做HmacSHA256后base64
+func (r *Robot) signedURL(now time.Time) (string, error) {
+	if r.Secret == "" {
+		return r.URL, nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return "", err
+	}
+	ts := strconv.FormatInt(now.UnixNano()/1e6, 10)
+	h := hmac.New(sha256.New, []byte(r.Secret))
+	h.Write([]byte(ts + "\n" + r.Secret))
+	q := u.Query()
+	q.Set("timestamp", ts)
+	q.Set("sign", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP 最简单的smtp服务，记录收到的命令和邮件内容
+func fakeSMTP(t *testing.T) (addr string, got chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				got <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			if inData {
+				if line == "." {
+					inData = false
+					conn.Write([]byte("250 OK\r\n"))
+				}
+				continue
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250-localhost\r\n250 AUTH PLAIN\r\n"))
+			case strings.HasPrefix(line, "AUTH"):
+				conn.Write([]byte("235 OK\r\n"))
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				got <- lines
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+	return l.Addr().String(), got
+}
+
+func TestSMTP(t *testing.T) {
+	addr, got := fakeSMTP(t)
+	s, err := NewSMTP(addr, "mts@localhost", "pwd", "", []string{"me@localhost"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.Notify(ctx, Message{Kind: KindSuccess, Title: "抢购成功", Content: "订单编号: 123", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := <-got
+	all := strings.Join(lines, "\n")
+	for _, want := range []string{"AUTH PLAIN", "MAIL FROM:<mts@localhost>", "RCPT TO:<me@localhost>", "Subject: =?UTF-8?B?"} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("missing %q in\n%s", want, all)
+		}
+	}
+	// 邮件正文为base64编码
+	var body string
+	for i, l := range lines {
+		if l == "" && i > 0 {
+			body = strings.Join(lines[i+1:len(lines)-2], "")
+			break
+		}
+	}
+	b, _ := base64.StdEncoding.DecodeString(body)
+	if !strings.Contains(string(b), "订单编号: 123") {
+		t.Fatalf("unexpected body %q", b)
+	}
+}
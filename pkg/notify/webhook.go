@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Webhook post the message as json to a url
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewWebhook return a generic json webhook notifier
+func NewWebhook(url string, headers map[string]string) (*Webhook, error) {
+	if url == "" {
+		return nil, errors.New("webhook must have url")
+	}
+	return &Webhook{URL: url, Headers: headers, Client: http.DefaultClient}, nil
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(ctx context.Context, m Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = postJSON(ctx, w.Client, w.URL, w.Headers, b)
+	return err
+}
+
+// postJSON 发送json请求，非2xx响应返回错误
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return b, fmt.Errorf("httpCode: %d, body: %s", resp.StatusCode, b)
+	}
+	return b, nil
+}
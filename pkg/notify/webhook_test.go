@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var got Message
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer ts.Close()
+
+	w, err := NewWebhook(ts.URL, map[string]string{"Authorization": "Bearer abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Notify(context.Background(), Message{Kind: KindSuccess, Title: "抢购成功", SkuId: "100012043978"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindSuccess || got.SkuId != "100012043978" || auth != "Bearer abc" {
+		t.Fatalf("unexpected request %+v %s", got, auth)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	w, _ := NewWebhook(ts.URL, nil)
+	if err := w.Notify(context.Background(), Message{Kind: KindFailure}); err == nil {
+		t.Fatal("expected error on 502")
+	}
+}
+
+func TestRobot(t *testing.T) {
+	var body map[string]interface{}
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	r, _ := NewRobot(ts.URL+"/robot/send?access_token=x", "SEC123")
+	if err := r.Notify(context.Background(), Message{Kind: KindStarting, Title: "即将开始"}); err != nil {
+		t.Fatal(err)
+	}
+	if body["msgtype"] != "text" {
+		t.Fatalf("unexpected body %v", body)
+	}
+	q, _ := parseQuery(query)
+	if q["access_token"] != "x" || q["sign"] == "" || q["timestamp"] == "" {
+		t.Fatalf("unexpected query %s", query)
+	}
+}
+
+func TestRobotErrcode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":93000,"errmsg":"invalid webhook url"}`))
+	}))
+	defer ts.Close()
+
+	r, _ := NewRobot(ts.URL, "")
+	if err := r.Notify(context.Background(), Message{Kind: KindFailure}); err == nil {
+		t.Fatal("expected errcode error")
+	}
+}
@@ -0,0 +1,316 @@
+// Package recorder writes every http exchange as one json object per line,
+// with timing breakdown, size limited bodies and redaction of secrets.
+package recorder
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RuleCookie 屏蔽Cookie和Set-Cookie的值，保留cookie名
+const RuleCookie = "cookie"
+
+// Masked 替换被屏蔽的值
+const Masked = "***"
+
+// DefaultRules 默认屏蔽cookie以及登陆、风控相关参数
+var DefaultRules = []string{RuleCookie, "password", "mobileKey", "eid", "fp"}
+
+// DefaultBodyLimit 默认每个body最多记录64KB
+const DefaultBodyLimit = 64 << 10
+
+// Timings 各阶段耗时，单位毫秒，含义同HAR，未发生的阶段为-1
+type Timings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	Total   float64 `json:"total"`
+}
+
+// Entry is one recorded request and response
+type Entry struct {
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"requestHeaders,omitempty"`
+	RequestBody     string      `json:"requestBody,omitempty"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"responseHeaders,omitempty"`
+	ResponseBody    string      `json:"responseBody,omitempty"`
+	// BodySize 响应body的实际长度，ResponseBody超过限制时被截断
+	BodySize  int64   `json:"bodySize"`
+	Truncated bool    `json:"truncated,omitempty"`
+	Timings   Timings `json:"timings"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Options of a Recorder
+type Options struct {
+	// BodyLimit 每个body最多记录的字节数，0为DefaultBodyLimit，负数不记录body
+	BodyLimit int
+	// Rules 需要屏蔽的参数名，RuleCookie表示屏蔽cookie，nil为DefaultRules
+	Rules []string
+}
+
+// Recorder writes entries to w
+type Recorder struct {
+	mu        sync.Mutex
+	enc       *json.Encoder
+	bodyLimit int
+	cookie    bool
+	params    []*regexp.Regexp
+	jsons     []*regexp.Regexp
+}
+
+// New return a recorder writing json lines to w
+func New(w io.Writer, opts Options) *Recorder {
+	r := &Recorder{enc: json.NewEncoder(w), bodyLimit: opts.BodyLimit}
+	r.enc.SetEscapeHTML(false)
+	if r.bodyLimit == 0 {
+		r.bodyLimit = DefaultBodyLimit
+	}
+	rules := opts.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+	for _, rule := range rules {
+		if strings.EqualFold(rule, RuleCookie) {
+			r.cookie = true
+			continue
+		}
+		k := regexp.QuoteMeta(rule)
+		// 查询参数、表单：key=value
+		r.params = append(r.params, regexp.MustCompile(`(^|[?&])(`+k+`)=[^&#"\s]*`))
+		// json、jsonp：\"key\":value
+		r.jsons = append(r.jsons, regexp.MustCompile(`("`+k+`"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`))
+	}
+	return r
+}
+
+// Redact 按规则屏蔽s中的参数值
+func (r *Recorder) Redact(s string) string {
+	for _, re := range r.params {
+		s = re.ReplaceAllString(s, "${1}${2}="+Masked)
+	}
+	for _, re := range r.jsons {
+		s = re.ReplaceAllString(s, `${1}"`+Masked+`"`)
+	}
+	return s
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		ck := http.CanonicalHeaderKey(k)
+		for _, v := range vs {
+			switch {
+			case r.cookie && ck == "Cookie":
+				v = maskCookies(v, ";")
+			case r.cookie && ck == "Set-Cookie":
+				v = maskCookies(v, "")
+			default:
+				v = r.Redact(v)
+			}
+			out[ck] = append(out[ck], v)
+		}
+	}
+	return out
+}
+
+// maskCookies 屏蔽cookie值，sep为空时只屏蔽第一个(Set-Cookie的属性保留)
+func maskCookies(v string, sep string) string {
+	parts := []string{v}
+	if sep != "" {
+		parts = strings.Split(v, sep)
+	} else if i := strings.Index(v, ";"); i >= 0 {
+		name := v[:i]
+		if j := strings.Index(name, "="); j >= 0 {
+			name = name[:j+1] + Masked
+		}
+		return name + v[i:]
+	}
+	for i, p := range parts {
+		if j := strings.Index(p, "="); j >= 0 {
+			parts[i] = p[:j+1] + Masked
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func (r *Recorder) limit(b []byte) string {
+	if r.bodyLimit < 0 {
+		return ""
+	}
+	if len(b) > r.bodyLimit {
+		b = b[:r.bodyLimit]
+	}
+	return r.Redact(string(b))
+}
+
+func (r *Recorder) write(e *Entry) {
+	r.mu.Lock()
+	_ = r.enc.Encode(e)
+	r.mu.Unlock()
+}
+
+// RoundTripper return a transport which records every exchange made by next,
+// nil next means http.DefaultTransport
+func (r *Recorder) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{rec: r, next: next}
+}
+
+type transport struct {
+	rec  *Recorder
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := &Entry{
+		Time:           time.Now(),
+		Method:         req.Method,
+		URL:            t.rec.Redact(req.URL.String()),
+		RequestHeaders: t.rec.redactHeader(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		e.RequestBody = t.rec.limit(b)
+	}
+
+	tr := &tracer{start: e.Time}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		e.Error = err.Error()
+		e.Timings = tr.timings(time.Now())
+		t.rec.write(e)
+		return nil, err
+	}
+	e.Status = resp.StatusCode
+	e.ResponseHeaders = t.rec.redactHeader(resp.Header)
+	resp.Body = &body{ReadCloser: resp.Body, rec: t.rec, entry: e, tracer: tr}
+	return resp, nil
+}
+
+// body 读完或关闭时写入记录
+type body struct {
+	io.ReadCloser
+	rec    *Recorder
+	entry  *Entry
+	tracer *tracer
+	buf    bytes.Buffer
+	once   sync.Once
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.entry.BodySize += int64(n)
+	if room := b.rec.bodyLimit - b.buf.Len(); room > 0 {
+		if n < room {
+			room = n
+		}
+		b.buf.Write(p[:room])
+	}
+	if err == io.EOF {
+		b.finish()
+	} else if err != nil {
+		b.entry.Error = err.Error()
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *body) finish() {
+	b.once.Do(func() {
+		b.entry.ResponseBody = b.rec.limit(b.buf.Bytes())
+		b.entry.Truncated = b.rec.bodyLimit >= 0 && b.entry.BodySize > int64(b.rec.bodyLimit)
+		b.entry.Timings = b.tracer.timings(time.Now())
+		b.rec.write(b.entry)
+	})
+}
+
+// tracer 通过httptrace记录各阶段时间点
+type tracer struct {
+	mu                    sync.Mutex
+	start                 time.Time
+	dnsStart, dnsDone     time.Time
+	connStart, connDone   time.Time
+	tlsStart, tlsDone     time.Time
+	gotConn, wroteRequest time.Time
+	firstByte             time.Time
+}
+
+func (t *tracer) set(p *time.Time) {
+	t.mu.Lock()
+	*p = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.set(&t.connStart) },
+		ConnectDone:          func(string, string, error) { t.set(&t.connDone) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { t.set(&t.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+func ms(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from).Microseconds()) / 1e3
+}
+
+func (t *tracer) timings(end time.Time) Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sendFrom := t.gotConn
+	if sendFrom.IsZero() {
+		sendFrom = t.start
+	}
+	recvFrom := t.firstByte
+	if recvFrom.IsZero() {
+		recvFrom = end
+	}
+	return Timings{
+		DNS:     ms(t.dnsStart, t.dnsDone),
+		Connect: ms(t.connStart, t.connDone),
+		SSL:     ms(t.tlsStart, t.tlsDone),
+		Send:    ms(sendFrom, t.wroteRequest),
+		Wait:    ms(t.wroteRequest, t.firstByte),
+		Receive: ms(recvFrom, end),
+		Total:   ms(t.start, end),
+	}
+}
@@ -0,0 +1,92 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r := New(ioutil.Discard, Options{})
+	cases := map[string]string{
+		"https://a.jd.com/x?eid=abc&fp=123&skuId=1":       "https://a.jd.com/x?eid=***&fp=***&skuId=1",
+		"password=123456&num=1":                           "password=***&num=1",
+		`{"eid":"abc","mobileKey": 12, "skuId":"1"}`:      `{"eid":"***","mobileKey": "***", "skuId":"1"}`,
+		`jQuery1({"fp":"a\"b","url":"//x.jd.com?eid=1"})`: `jQuery1({"fp":"***","url":"//x.jd.com?eid=***"})`,
+		"feid=1&xfp=2": "feid=1&xfp=2",
+	}
+	for in, want := range cases {
+		if got := r.Redact(in); got != want {
+			t.Errorf("Redact(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestRoundTripper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "thor", Value: "secret", Path: "/"})
+		w.Write([]byte(`{"orderId":"1","eid":"abc","pad":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	rec := New(&out, Options{BodyLimit: 40})
+	client := &http.Client{Transport: rec.RoundTripper(nil)}
+	form := url.Values{"password": {"123"}, "num": {"2"}}
+	req, _ := http.NewRequest("POST", ts.URL+"/submit?fp=9", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "pt_key", Value: "k"})
+	req.AddCookie(&http.Cookie{Name: "pt_pin", Value: "p"})
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), `"eid":"abc"`) {
+		t.Fatalf("response body must not be modified: %s", b)
+	}
+
+	var e Entry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatal(err, out.String())
+	}
+	if e.Method != "POST" || e.Status != 200 || !strings.HasSuffix(e.URL, "/submit?fp=***") {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e.RequestBody != "num=2&password=***" {
+		t.Fatalf("request body %s", e.RequestBody)
+	}
+	if got := e.RequestHeaders.Get("Cookie"); got != "pt_key=***; pt_pin=***" {
+		t.Fatalf("cookie %s", got)
+	}
+	if got := e.ResponseHeaders.Get("Set-Cookie"); got != "thor=***; Path=/" {
+		t.Fatalf("set-cookie %s", got)
+	}
+	if !e.Truncated || e.BodySize != int64(len(b)) || e.ResponseBody != `{"orderId":"1","eid":"***","pad":"xxxxxx` {
+		t.Fatalf("response body %s %d %v", e.ResponseBody, e.BodySize, e.Truncated)
+	}
+	if e.Timings.Total < 0 || e.Timings.Wait < 0 || e.Timings.Connect < 0 {
+		t.Fatalf("timings %+v", e.Timings)
+	}
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "123") {
+		t.Fatalf("secret leaked: %s", out.String())
+	}
+}
+
+func TestRoundTripperError(t *testing.T) {
+	var out bytes.Buffer
+	client := &http.Client{Transport: New(&out, Options{}).RoundTripper(nil)}
+	if _, err := client.Get("http://127.0.0.1:1/x"); err == nil {
+		t.Fatal("expected error")
+	}
+	var e Entry
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil || e.Error == "" {
+		t.Fatalf("unexpected entry %s", out.String())
+	}
+}
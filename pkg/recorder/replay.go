@@ -0,0 +1,174 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Load read the entries written by a Recorder
+func Load(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// 回放时不复制的响应头，body已被解码、截断或屏蔽
+var skipHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// Replayer answers requests with recorded responses. Requests are matched by
+// method, host and path; every match returns the next recorded response and
+// the last one is repeated once they are used up.
+type Replayer struct {
+	// Speed 按录制的wait+receive耗时除以Speed延迟响应，0为不延迟
+	Speed float64
+
+	mu      sync.Mutex
+	entries map[string][]Entry
+	served  map[string]int
+}
+
+// NewReplayer return a replayer of entries, failed exchanges are ignored
+func NewReplayer(entries []Entry) *Replayer {
+	p := &Replayer{Speed: 1, entries: map[string][]Entry{}, served: map[string]int{}}
+	for _, e := range entries {
+		if e.Error != "" && e.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		k := replayKey(e.Method, u.Host, u.Path)
+		p.entries[k] = append(p.entries[k], e)
+	}
+	return p
+}
+
+func replayKey(method, host, path string) string {
+	return method + " " + host + path
+}
+
+// Len return the number of distinct recorded endpoints
+func (p *Replayer) Len() int {
+	return len(p.entries)
+}
+
+// next return the next response of the request
+func (p *Replayer) next(r *http.Request) (Entry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k := replayKey(r.Method, r.Host, r.URL.Path)
+	es := p.entries[k]
+	if len(es) == 0 {
+		return Entry{}, false
+	}
+	i := p.served[k]
+	if i >= len(es) {
+		i = len(es) - 1
+	}
+	p.served[k]++
+	return es[i], true
+}
+
+// ServeHTTP implements http.Handler, unknown requests get an empty 404
+func (p *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e, ok := p.next(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if p.Speed > 0 {
+		var d float64
+		if e.Timings.Wait > 0 {
+			d += e.Timings.Wait
+		}
+		if e.Timings.Receive > 0 {
+			d += e.Timings.Receive
+		}
+		t := time.NewTimer(time.Duration(d / p.Speed * float64(time.Millisecond)))
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+	for k, vs := range e.ResponseHeaders {
+		if skipHeaders[k] {
+			continue
+		}
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(e.Status)
+	io.WriteString(w, jsonpBody(e, r))
+}
+
+// jsonpBody 将录制响应中的jsonp callback替换为本次请求的callback
+func jsonpBody(e Entry, r *http.Request) string {
+	cb := r.URL.Query().Get("callback")
+	if cb == "" {
+		return e.ResponseBody
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return e.ResponseBody
+	}
+	old := u.Query().Get("callback")
+	body := strings.TrimSpace(e.ResponseBody)
+	if old == "" || !strings.HasPrefix(body, old) {
+		return e.ResponseBody
+	}
+	return cb + body[len(old):]
+}
+
+// Transport return a transport which sends every request to addr over plain
+// http, the original host is kept in the Host header
+func Transport(addr string) http.RoundTripper {
+	return &rewriteTransport{addr: addr, next: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+	}}
+}
+
+type rewriteTransport struct {
+	addr string
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Host = req.URL.Host
+	r.URL.Scheme = "http"
+	r.URL.Host = t.addr
+	resp, err := t.next.RoundTrip(r)
+	if resp != nil {
+		// 保持原始请求，重定向和登陆检测看到的仍是原始地址
+		resp.Request = req
+	}
+	return resp, err
+}
@@ -0,0 +1,75 @@
+package recorder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	// 录制：init第一次返回空，第二次返回数据
+	n := 0
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/itemShowBtn":
+			w.Write([]byte(r.URL.Query().Get("callback") + `({"url":"//divide.jd.com/user_routing"})`))
+		case "/init.action":
+			n++
+			if n == 1 {
+				w.Write([]byte("null"))
+				return
+			}
+			w.Write([]byte(`{"token":"t"}`))
+		}
+	}))
+	defer origin.Close()
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: New(&out, Options{}).RoundTripper(nil)}
+	get := func(c *http.Client, method, u string) string {
+		req, _ := http.NewRequest(method, u, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+	get(client, "GET", origin.URL+"/itemShowBtn?callback=jQuery111")
+	get(client, "POST", origin.URL+"/init.action")
+	get(client, "POST", origin.URL+"/init.action")
+
+	entries, err := Load(&out)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("load %d entries %v", len(entries), err)
+	}
+	p := NewReplayer(entries)
+	p.Speed = 0
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	// 原始地址被转发到回放服务
+	host := strings.TrimPrefix(origin.URL, "http://")
+	replay := &http.Client{Transport: Transport(strings.TrimPrefix(ts.URL, "http://"))}
+	if got := get(replay, "GET", "https://"+host+"/itemShowBtn?callback=jQuery222"); got != `jQuery222({"url":"//divide.jd.com/user_routing"})` {
+		t.Fatalf("itemShowBtn %s", got)
+	}
+	for _, want := range []string{"null", `{"token":"t"}`, `{"token":"t"}`} {
+		if got := get(replay, "POST", "https://"+host+"/init.action"); got != want {
+			t.Fatalf("init.action %s, want %s", got, want)
+		}
+	}
+	req, _ := http.NewRequest("GET", "https://"+host+"/submitOrder.action", nil)
+	resp, err := replay.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown request got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Screen 在终端的备用屏幕上绘制界面，Stop后恢复原来的屏幕内容
+type Screen struct {
+	mu      sync.Mutex
+	out     io.Writer
+	started bool
+}
+
+// NewScreen return a screen drawing to out
+func NewScreen(out io.Writer) *Screen {
+	return &Screen{out: out}
+}
+
+// Start 切换到备用屏幕并隐藏光标
+func (s *Screen) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.started = true
+		io.WriteString(s.out, "\x1b[?1049h\x1b[?25l")
+	}
+}
+
+// Draw 从左上角开始重绘，清除每行和最后一行之后的旧内容
+func (s *Screen) Draw(lines []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	s.out.Write(b.Bytes())
+}
+
+// Stop 显示光标并回到原来的屏幕
+func (s *Screen) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		s.started = false
+		io.WriteString(s.out, "\x1b[?25h\x1b[?1049l")
+	}
+}
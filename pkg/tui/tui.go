@@ -0,0 +1,286 @@
+// Package tui 在终端中绘制抢购过程的实时界面，只依赖ANSI控制序列
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Worker 一个并发最近一次请求的状态
+type Worker struct {
+	ID       int
+	Stage    string
+	Result   string
+	Latency  float64 // 最近一次请求的耗时，单位ms
+	Attempts int64
+}
+
+// Counter 按顺序显示的计数
+type Counter struct {
+	Name  string
+	Value int64
+}
+
+// Series 一个阶段的请求耗时序列，单位ms
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// State 一帧界面的内容
+type State struct {
+	Title       string
+	Phase       string
+	Sku         string
+	User        string
+	Login       string
+	Address     string
+	OrderId     string
+	StartTime   time.Time
+	Countdown   time.Duration // 按服务器时间计算的剩余时间，小于0为已开始
+	Synced      bool          // 已同步服务器时间
+	Offset      int64         // 本地与服务器的时间差，单位ms
+	Uncertainty int64         // Offset的误差，0为未知
+	Counters    []Counter
+	Workers     []Worker
+	Latency     []Series
+	Logs        []string
+}
+
+// 倒计时使用的5行大字
+var glyphs = map[rune][5]string{
+	'0': {"███", "█ █", "█ █", "█ █", "███"},
+	'1': {" █ ", "██ ", " █ ", " █ ", "███"},
+	'2': {"███", "  █", "███", "█  ", "███"},
+	'3': {"███", "  █", "███", "  █", "███"},
+	'4': {"█ █", "█ █", "███", "  █", "  █"},
+	'5': {"███", "█  ", "███", "  █", "███"},
+	'6': {"███", "█  ", "███", "█ █", "███"},
+	'7': {"███", "  █", "  █", "  █", "  █"},
+	'8': {"███", "█ █", "███", "█ █", "███"},
+	'9': {"███", "█ █", "███", "  █", "███"},
+	':': {" ", "█", " ", "█", " "},
+	'.': {" ", " ", " ", " ", "█"},
+	'+': {"   ", " █ ", "███", " █ ", "   "},
+	'-': {"   ", "   ", "███", "   ", "   "},
+}
+
+// BigText 返回s的5行大字，不支持的字符显示为空白
+func BigText(s string) [5]string {
+	var rows [5]string
+	for i, r := range s {
+		g, ok := glyphs[r]
+		if !ok {
+			g = [5]string{" ", " ", " ", " ", " "}
+		}
+		for j := range rows {
+			if i > 0 {
+				rows[j] += " "
+			}
+			rows[j] += g[j]
+		}
+	}
+	return rows
+}
+
+// FormatCountdown 格式化倒计时，精确到0.1s，已开始时为+经过的时间
+func FormatCountdown(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "+", -d
+	}
+	d = d.Truncate(100 * time.Millisecond)
+	h, m := int(d/time.Hour), int(d/time.Minute)%60
+	s, ds := int(d/time.Second)%60, int(d/(100*time.Millisecond))%10
+	if h > 0 {
+		return fmt.Sprintf("%s%d:%02d:%02d.%d", sign, h, m, s, ds)
+	}
+	return fmt.Sprintf("%s%02d:%02d.%d", sign, m, s, ds)
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline 用最近width个值绘制迷你折线图，按序列中的最大值缩放
+func Sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	b := make([]rune, 0, width)
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(sparks)-1))
+		}
+		b = append(b, sparks[i])
+	}
+	return string(b)
+}
+
+// percentile 返回values的p分位数
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), values...)
+	sort.Float64s(s)
+	return s[int(float64(len(s)-1)*p)]
+}
+
+// runeWidth 返回r在终端中占用的列数，中文等宽字符为2
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf, r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff, r >= 0xfe30 && r <= 0xfe4f, r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6, r >= 0x1f300 && r <= 0x1f64f, r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// Width 返回s在终端中占用的列数
+func Width(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// Truncate 将s截断到不超过width列
+func Truncate(s string, width int) string {
+	n := 0
+	for i, r := range s {
+		if n += runeWidth(r); n > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// pad 在s后补空格到width列
+func pad(s string, width int) string {
+	if w := Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// center 将s居中到width列
+func center(s string, width int) string {
+	if w := Width(s); w < width {
+		return strings.Repeat(" ", (width-w)/2) + s
+	}
+	return s
+}
+
+// Render 按终端大小绘制一帧，返回不超过height行、每行不超过width列的内容
+func Render(s *State, width, height int) []string {
+	var lines []string
+	add := func(format string, v ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	}
+	rule := strings.Repeat("─", width)
+
+	header := s.Title + "  sku " + s.Sku + "  " + s.Phase
+	if s.OrderId != "" {
+		header += "  订单 " + s.OrderId
+	}
+	add("%s", header)
+	lines = append(lines, rule)
+	if s.StartTime.IsZero() {
+		add("")
+	} else {
+		for _, row := range BigText(FormatCountdown(s.Countdown)) {
+			lines = append(lines, center(row, width))
+		}
+	}
+	clock := "未同步"
+	if s.Synced {
+		clock = fmt.Sprintf("%+dms", s.Offset)
+		if s.Uncertainty > 0 {
+			clock += fmt.Sprintf(" ±%dms", s.Uncertainty)
+		}
+	}
+	login := s.Login
+	if s.User != "" {
+		login += " (" + s.User + ")"
+	}
+	start := ""
+	if !s.StartTime.IsZero() {
+		start = s.StartTime.Format("2006-01-02 15:04:05.000")
+	}
+	add("开始 %s  时钟偏差 %s", start, clock)
+	if s.Address != "" {
+		login += "  地址 " + s.Address
+	}
+	add("登录 %s", login)
+	lines = append(lines, rule)
+
+	counters := make([]string, 0, len(s.Counters))
+	for _, c := range s.Counters {
+		counters = append(counters, fmt.Sprintf("%s %d", c.Name, c.Value))
+	}
+	add("%s", strings.Join(counters, "  "))
+	if len(s.Workers) > 0 {
+		add("%s%s%s%s%s", pad("worker", 8), pad("阶段", 16), pad("结果", 8), pad("耗时", 11), "次数")
+	}
+	// 日志至少保留3行
+	maxWorkers := height - len(lines) - len(s.Latency) - 6
+	for i, w := range s.Workers {
+		if i >= maxWorkers && len(s.Workers) > maxWorkers+1 {
+			add("... 还有%d个worker", len(s.Workers)-i)
+			break
+		}
+		latency := "-"
+		if w.Latency > 0 {
+			latency = fmt.Sprintf("%.1fms", w.Latency)
+		}
+		add("%s%s%s%s%d", pad(fmt.Sprint(w.ID), 8), pad(w.Stage, 16), pad(w.Result, 8), pad(latency, 11), w.Attempts)
+	}
+	nameWidth := 0
+	for _, l := range s.Latency {
+		if w := Width(l.Name); w > nameWidth {
+			nameWidth = w
+		}
+	}
+	for _, l := range s.Latency {
+		if len(l.Values) == 0 {
+			continue
+		}
+		stats := fmt.Sprintf(" 最近%.1fms p50 %.1fms p99 %.1fms", l.Values[len(l.Values)-1],
+			percentile(l.Values, 0.5), percentile(l.Values, 0.99))
+		spark := width - nameWidth - 1 - Width(stats)
+		if spark > 60 {
+			spark = 60
+		}
+		add("%s %s%s", pad(l.Name, nameWidth), Sparkline(l.Values, spark), stats)
+	}
+	lines = append(lines, rule)
+
+	if n := height - len(lines); n > 0 {
+		logs := s.Logs
+		if len(logs) > n {
+			logs = logs[len(logs)-n:]
+		}
+		lines = append(lines, logs...)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, l := range lines {
+		lines[i] = Truncate(l, width)
+	}
+	return lines
+}
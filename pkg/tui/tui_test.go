@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatCountdown(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		1500 * time.Millisecond:                   "00:01.5",
+		62*time.Second + 99*time.Millisecond:      "01:02.0",
+		time.Hour + 2*time.Minute + 3*time.Second: "1:02:03.0",
+		-(2*time.Second + 350*time.Millisecond):   "+00:02.3",
+		0:                                         "00:00.0",
+	} {
+		if got := FormatCountdown(d); got != want {
+			t.Fatalf("%v: got %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestBigText(t *testing.T) {
+	rows := BigText("1:0")
+	if rows[0] != " █    ███" || rows[4] != "███   ███" {
+		t.Fatalf("unexpected glyphs %q", rows)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{0, 1, 2, 4, 8}, 10); got != "▁▁▂▄█" {
+		t.Fatalf("got %q", got)
+	}
+	// 只显示最近width个值
+	if got := Sparkline([]float64{8, 0, 8}, 2); got != "▁█" {
+		t.Fatalf("got %q", got)
+	}
+	if Sparkline([]float64{0, 0}, 5) != "▁▁" || Sparkline([]float64{1}, 0) != "" {
+		t.Fatal("unexpected sparkline of zero values")
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if Width("ab中文") != 6 || Truncate("ab中文", 5) != "ab中" || Truncate("abc", 5) != "abc" {
+		t.Fatal("unexpected width of wide runes")
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := &State{
+		Title:       "mts jd",
+		Phase:       "waiting",
+		Sku:         "100012043978",
+		Login:       "已登录",
+		User:        "jd_user",
+		Address:     "张三 北京市朝阳区",
+		StartTime:   time.Date(2026, 10, 19, 9, 59, 58, 0, time.Local),
+		Countdown:   3 * time.Second,
+		Synced:      true,
+		Offset:      -12,
+		Uncertainty: 8,
+		Counters:    []Counter{{"尝试", 3}, {"成功", 0}},
+		Workers:     []Worker{{ID: 1, Stage: "itemShowBtn", Result: "empty", Latency: 12.5, Attempts: 2}, {ID: 2}},
+		Latency:     []Series{{Name: "itemShowBtn", Values: []float64{10, 20, 15}}},
+	}
+	for i := 0; i < 50; i++ {
+		s.Logs = append(s.Logs, "log "+strings.Repeat("x", i))
+	}
+	lines := Render(s, 60, 24)
+	if len(lines) != 24 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	text := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"mts jd  sku 100012043978  waiting",
+		"时钟偏差 -12ms ±8ms",
+		"登录 已登录 (jd_user)  地址 张三 北京市朝阳区",
+		"尝试 3  成功 0",
+		"1       itemShowBtn     empty   12.5ms     2",
+		"itemShowBtn ▄█▆ 最近15.0ms",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in\n%s", want, text)
+		}
+	}
+	// 日志只保留能显示的最后几行，并截断到屏幕宽度
+	if last := lines[23]; last != "log "+strings.Repeat("x", 49) {
+		t.Fatalf("unexpected last line %q", last)
+	}
+	for _, l := range lines {
+		if Width(l) > 60 {
+			t.Fatalf("line too wide %q", l)
+		}
+	}
+}
+
+func TestRenderManyWorkers(t *testing.T) {
+	s := &State{Title: "mts jd"}
+	for i := 1; i <= 30; i++ {
+		s.Workers = append(s.Workers, Worker{ID: i})
+	}
+	lines := Render(s, 80, 20)
+	if len(lines) > 20 || !strings.Contains(strings.Join(lines, "\n"), "... 还有") {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	var b bytes.Buffer
+	s := NewScreen(&b)
+	s.Draw([]string{"ignored"})
+	s.Start()
+	s.Draw([]string{"a", "b"})
+	s.Stop()
+	s.Stop()
+	if got := b.String(); got != "\x1b[?1049h\x1b[?25l\x1b[Ha\x1b[K\r\nb\x1b[K\x1b[J\x1b[?25h\x1b[?1049l" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,271 @@
+// Package vault stores named secrets in a single file encrypted with
+// AES-256-GCM, the key is derived from a passphrase with scrypt.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// RefPrefix 配置中以该前缀开头的值表示引用vault中的条目，如vault:payPwd
+const RefPrefix = "vault:"
+
+var (
+	ErrExists          = errors.New("vault: file already exists")
+	ErrNotFound        = errors.New("vault: entry not found")
+	ErrWrongPassphrase = errors.New("vault: wrong passphrase or corrupted file")
+)
+
+// Params are the scrypt cost parameters
+type Params struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultParams 新建vault时使用的scrypt参数
+var DefaultParams = Params{N: 1 << 15, R: 8, P: 1}
+
+const (
+	version = 1
+	keyLen  = 32
+	saltLen = 16
+)
+
+// 读取文件时允许的scrypt参数上限，防止构造的文件导致超大内存分配
+const (
+	maxN   = 1 << 20
+	maxR   = 32
+	maxP   = 16
+	maxMem = 1 << 30
+)
+
+// file is the on disk format
+type file struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Params
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Vault is an opened vault, changes are written by Save
+type Vault struct {
+	path    string
+	key     []byte
+	header  file
+	entries map[string]string
+}
+
+// IsRef report whether v references a vault entry
+func IsRef(v string) bool {
+	return strings.HasPrefix(v, RefPrefix)
+}
+
+// RefName return the entry name of a reference
+func RefName(v string) string {
+	return strings.TrimPrefix(v, RefPrefix)
+}
+
+// Init create an empty vault at path, an existing file is never overwritten
+func Init(path string, passphrase []byte) (v *Vault, err error) {
+	// 先以O_EXCL占位，Save再用完整内容替换
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrExists
+		}
+		return nil, err
+	}
+	f.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	salt := make([]byte, saltLen)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	h := file{Version: version, KDF: "scrypt", Params: DefaultParams, Salt: salt}
+	key, err := deriveKey(passphrase, h)
+	if err != nil {
+		return nil, err
+	}
+	v = &Vault{path: path, key: key, header: h, entries: map[string]string{}}
+	if err = v.Save(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Open decrypt the vault at path
+func Open(path string, passphrase []byte) (*Vault, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h file
+	if err = json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("vault: %s is not a vault file: %v", path, err)
+	}
+	if h.Version != version || h.KDF != "scrypt" {
+		return nil, fmt.Errorf("vault: unsupported version %d kdf %s", h.Version, h.KDF)
+	}
+	if err = h.Params.check(); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, h.Nonce, h.Data, h.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	v := &Vault{path: path, key: key, header: h, entries: map[string]string{}}
+	if err = json.Unmarshal(plain, &v.entries); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return v, nil
+}
+
+// check 校验从文件读取的scrypt参数，N必须是2的幂且不超过上限
+func (p Params) check() error {
+	if p.N <= 1 || p.N > maxN || p.N&(p.N-1) != 0 ||
+		p.R < 1 || p.R > maxR || p.P < 1 || p.P > maxP ||
+		128*p.N*p.R > maxMem {
+		return fmt.Errorf("vault: scrypt params n=%d r=%d p=%d out of range", p.N, p.R, p.P)
+	}
+	return nil
+}
+
+func deriveKey(passphrase []byte, h file) ([]byte, error) {
+	return scrypt.Key(passphrase, h.Salt, h.N, h.R, h.P, keyLen)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData 将kdf参数作为附加数据，防止被篡改
+func (h file) additionalData() []byte {
+	return []byte(fmt.Sprintf("mts-vault:%d:%s:%d:%d:%d", h.Version, h.KDF, h.N, h.R, h.P))
+}
+
+// Get return the value of name
+func (v *Vault) Get(name string) (string, error) {
+	s, ok := v.entries[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return s, nil
+}
+
+// Set add or replace an entry
+func (v *Vault) Set(name, value string) {
+	v.entries[name] = value
+}
+
+// Delete remove an entry
+func (v *Vault) Delete(name string) error {
+	if _, ok := v.entries[name]; !ok {
+		return ErrNotFound
+	}
+	delete(v.entries, name)
+	return nil
+}
+
+// Names return the sorted entry names
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.entries))
+	for k := range v.entries {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypt the entries with a new nonce and replace the file atomically,
+// the temp file and the directory are synced so a crash never leaves an
+// empty vault, the file is only readable by the owner
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(v.key)
+	if err != nil {
+		return err
+	}
+	h := v.header
+	h.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(h.Nonce); err != nil {
+		return err
+	}
+	h.Data = aead.Seal(nil, h.Nonce, plain, h.additionalData())
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(v.path), "."+filepath.Base(v.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), v.path); err != nil {
+		return err
+	}
+	v.header = h
+	return syncDir(filepath.Dir(v.path))
+}
+
+// syncDir 同步目录使rename持久化，Windows不支持对目录Sync，忽略该错误
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
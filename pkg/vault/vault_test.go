@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// 测试时降低scrypt开销
+	DefaultParams = Params{N: 1 << 10, R: 8, P: 1}
+}
+
+func TestVault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mts.vault")
+	pass := []byte("correct horse")
+
+	v, err := Init(path, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Init(path, pass); err != ErrExists {
+		t.Fatalf("init twice: %v", err)
+	}
+	v.Set("payPwd", "123456")
+	v.Set("eid", "EID-VALUE")
+	if err = v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("perm %v", fi.Mode().Perm())
+	}
+	b, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(b), "123456") || strings.Contains(string(b), "EID-VALUE") {
+		t.Fatal("vault file is not encrypted")
+	}
+
+	if _, err = Open(path, []byte("wrong")); err != ErrWrongPassphrase {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	v, err = Open(path, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := v.Get("payPwd"); err != nil || s != "123456" {
+		t.Fatalf("get %s %v", s, err)
+	}
+	if err = v.Delete("payPwd"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Delete("payPwd"); err != ErrNotFound {
+		t.Fatalf("delete twice: %v", err)
+	}
+	if err = v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	v, _ = Open(path, pass)
+	if names := v.Names(); len(names) != 1 || names[0] != "eid" {
+		t.Fatalf("names %v", names)
+	}
+}
+
+func TestTamper(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mts.vault")
+	if _, err := Init(path, []byte("p")); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	// 修改kdf参数后无法解密
+	b = []byte(strings.Replace(string(b), `"r":8`, `"r":9`, 1))
+	ioutil.WriteFile(path, b, 0600)
+	if _, err := Open(path, []byte("p")); err != ErrWrongPassphrase {
+		t.Fatalf("tampered vault: %v", err)
+	}
+}
+
+func TestParamsLimit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "vault")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mts.vault")
+	if _, err := Init(path, []byte("p")); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	for _, c := range []struct{ old, new string }{
+		{`"n":1024`, `"n":1073741824`},
+		{`"n":1024`, `"n":1000`},
+		{`"r":8`, `"r":1024`},
+		{`"p":1`, `"p":0`},
+	} {
+		ioutil.WriteFile(path, []byte(strings.Replace(string(b), c.old, c.new, 1)), 0600)
+		if _, err := Open(path, []byte("p")); err == nil || err == ErrWrongPassphrase || !strings.Contains(err.Error(), "out of range") {
+			t.Fatalf("%s: %v", c.new, err)
+		}
+	}
+}
+
+func TestRef(t *testing.T) {
+	if !IsRef("vault:payPwd") || RefName("vault:payPwd") != "payPwd" || IsRef("123456") {
+		t.Fatal("ref")
+	}
+}